@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/audit"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/mailer"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// rejectDisabledUser responds with 403 and returns true when the account is disabled
+func rejectDisabledUser(c *gin.Context, user models.User) bool {
+	if user.Status != models.UserStatusDisabled {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "Your account has been disabled",
+	})
+	return true
+}
+
+// sendAccountInviteEmail mails a link to a user created by an admin to choose a password
+func sendAccountInviteEmail(user models.User) error {
+	ttl := config.GetDuration("ACCOUNT_INVITE_TTL", 72*time.Hour)
+
+	link, err := passwordResetLink(user, ttl)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Default.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your account has been created",
+		Body: fmt.Sprintf("Hi %s,\n\nAn account has been created for you. Open the link below to choose your password:\n\n%s\n\nThe link expires in %s.\n",
+			user.Name, link, ttl),
+	})
+}
+
+// CreateUser function is used by an admin to create a user, the user chooses a password from the emailed link
+func CreateUser(c *gin.Context) {
+	var userInput struct {
+		Name  string   `json:"name" binding:"required,min=2,max=50"`
+		Email string   `json:"email" binding:"required,email"`
+		Roles []string `json:"roles"`
+	}
+
+	if err := c.ShouldBindJSON(&userInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Choosing the roles needs the permission to assign roles, otherwise the user gets the default role
+	roleNames := []string{rbac.DefaultSignupRole()}
+	if len(userInput.Roles) > 0 {
+		authUser := helpers.GetAuthUser(c)
+		if authUser == nil {
+			return
+		}
+
+		if !authUser.HasPermission(rbac.RolesAssign) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "You do not have permission to assign roles",
+			})
+			return
+		}
+
+		roleNames = userInput.Roles
+	}
+
+	roles, err := rbac.FindRoles(initializers.DB, roleNames)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Roles": err.Error(),
+			},
+		})
+		return
+	}
+
+	// Email unique validation
+	if validations.IsUniqueValue("users", "email", userInput.Email, 0) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Email": "The email is already exist!",
+			},
+		})
+		return
+	}
+
+	// The user has no password until the invite link is used
+	user := models.User{
+		Name:   userInput.Name,
+		Email:  userInput.Email,
+		Status: models.UserStatusActive,
+		Roles:  roles,
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionCreate, audit.ResourceUser, user.ID, nil, user)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// A new link can be sent with the forgot password form if this fails
+	if err := sendAccountInviteEmail(user); err != nil {
+		log.Println("Failed to send the account invite email:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+// DisableUser function is used to disable a user account and log it out everywhere
+func DisableUser(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// Admins cannot lock themselves out
+	if user.ID == authUser.ID {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "You cannot disable your own account",
+		})
+		return
+	}
+
+	before := user
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("status", models.UserStatusDisabled).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionUpdate, audit.ResourceUser, user.ID, before, user)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if err := tokens.RevokeAllSessions(initializers.DB, user.ID); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+// EnableUser function is used to enable a disabled user account
+func EnableUser(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	before := user
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("status", models.UserStatusActive).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionUpdate, audit.ResourceUser, user.ID, before, user)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+// LogoutUser function is used to log a user out from every session
+func LogoutUser(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if err := tokens.RevokeAllSessions(initializers.DB, user.ID); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "The user has been logged out from all sessions",
+	})
+}
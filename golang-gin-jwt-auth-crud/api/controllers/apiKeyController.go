@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// ListAPIKeys lists the API keys of the logged in user, the keys themselves are never shown again
+func ListAPIKeys(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var keys []models.APIKey
+	if err := initializers.DB.Where("user_id = ?", authUser.ID).Order("id desc").Find(&keys).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_keys": keys,
+	})
+}
+
+// CreateAPIKey creates an API key for the logged in user with some of the user's permissions as scopes
+func CreateAPIKey(c *gin.Context) {
+	var keyInput struct {
+		Name      string     `json:"name" binding:"required,min=2,max=100"`
+		Scopes    []string   `json:"scopes" binding:"required,min=1"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := c.ShouldBindJSON(&keyInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	// A key can only carry permissions the user has
+	for _, scope := range keyInput.Scopes {
+		if !authUser.HasPermission(scope) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": map[string]interface{}{
+					"Scopes": "You do not have the permission " + scope,
+				},
+			})
+			return
+		}
+	}
+
+	if keyInput.ExpiresAt != nil && !keyInput.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"ExpiresAt": "ExpiresAt must be in the future",
+			},
+		})
+		return
+	}
+
+	raw, prefix, hash, err := tokens.NewAPIKey()
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	key := models.APIKey{
+		UserID:    authUser.ID,
+		Name:      keyInput.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    strings.Join(keyInput.Scopes, " "),
+		ExpiresAt: keyInput.ExpiresAt,
+	}
+
+	if err := initializers.DB.Create(&key).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// The key is only shown once
+	c.JSON(http.StatusOK, gin.H{
+		"api_key": key,
+		"key":     raw,
+	})
+}
+
+// RevokeAPIKey revokes an API key of the logged in user
+func RevokeAPIKey(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var key models.APIKey
+	if err := initializers.DB.Where("user_id = ?", authUser.ID).First(&key, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if key.RevokedAt == nil {
+		if err := initializers.DB.Model(&key).Update("revoked_at", time.Now()).Error; err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "The API key has been revoked",
+	})
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/api/middleware"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/audit"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errRecordChanged rolls back an update of a record that was changed since it was read
+var errRecordChanged = errors.New("the record was changed")
+
+// recordAudit writes to the audit log that the authenticated user changed a record. before is nil for a
+// created record and after is nil for a deleted one. tx is the transaction of the change, a failed write
+// rolls the change back so no change is made without its entry and no entry is left without its change.
+func recordAudit(tx *gorm.DB, c *gin.Context, action, resourceType string, resourceID uint, before, after interface{}) error {
+	entry := models.AuditLog{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		IP:           c.ClientIP(),
+	}
+
+	if value, ok := c.Get("authUser"); ok {
+		if authUser, ok := value.(middleware.AuthUser); ok {
+			entry.ActorID = &authUser.ID
+			if authUser.APIKeyID != 0 {
+				entry.APIKeyID = &authUser.APIKeyID
+			}
+		}
+	}
+
+	return audit.Record(tx, entry, before, after)
+}
+
+// authUserID returns the id of the authenticated user, nil for an anonymous request
+func authUserID(c *gin.Context) *uint {
+	if value, ok := c.Get("authUser"); ok {
+		if authUser, ok := value.(middleware.AuthUser); ok {
+			return &authUser.ID
+		}
+	}
+
+	return nil
+}
+
+// parseAuditTime reads a time filter given as RFC 3339 or as a date
+func parseAuditTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02", value)
+}
+
+// ListAuditLogs lists the audit log, newest first. It can be filtered by actor_id, action, resource_type,
+// resource_id, field (a changed field such as "price") and from / to (RFC 3339 or yyyy-mm-dd, to is exclusive).
+func ListAuditLogs(c *gin.Context) {
+	var logs []models.AuditLog
+
+	page, ok := pageParams(c, "perPage", 20)
+	if !ok {
+		return
+	}
+
+	conditions := make([]func(*gorm.DB) *gorm.DB, 0)
+	invalid := make(map[string]interface{})
+
+	for _, name := range []string{"actor_id", "resource_id"} {
+		if value := c.Query(name); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				invalid[name] = name + " must be a number"
+				continue
+			}
+
+			column := name
+			conditions = append(conditions, func(query *gorm.DB) *gorm.DB {
+				return query.Where(column+" = ?", id)
+			})
+		}
+	}
+
+	for _, name := range []string{"action", "resource_type"} {
+		if value := c.Query(name); value != "" {
+			column := name
+			conditions = append(conditions, func(query *gorm.DB) *gorm.DB {
+				return query.Where(column+" = ?", value)
+			})
+		}
+	}
+
+	// jsonb_exists is the ? operator, which cannot be used next to query placeholders
+	if field := c.Query("field"); field != "" {
+		conditions = append(conditions, func(query *gorm.DB) *gorm.DB {
+			return query.Where("jsonb_exists(changes, ?)", field)
+		})
+	}
+
+	for name, operator := range map[string]string{"from": ">=", "to": "<"} {
+		if value := c.Query(name); value != "" {
+			t, err := parseAuditTime(value)
+			if err != nil {
+				invalid[name] = name + " must be a date or an RFC 3339 time"
+				continue
+			}
+
+			condition := "created_at " + operator + " ?"
+			conditions = append(conditions, func(query *gorm.DB) *gorm.DB {
+				return query.Where(condition, t)
+			})
+		}
+	}
+
+	if len(invalid) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": invalid,
+		})
+		return
+	}
+
+	rawFunc := func(query *gorm.DB) *gorm.DB {
+		for _, condition := range conditions {
+			query = condition(query)
+		}
+
+		return query.Order("id desc").Preload("Actor", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, name, email")
+		})
+	}
+
+	result, err := pagination.Paginate(initializers.DB, page.Page, page.Limit, rawFunc, &logs)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result": result,
+	})
+}
@@ -1,15 +1,21 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/audit"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/filters"
 	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/inventory"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
 )
 
 // CreateBook creates a new book
@@ -41,16 +47,36 @@ func CreateBook(c *gin.Context) {
 		return
 	}
 
-	// Create the book
+	// Create the book, its initial stock is received like any later delivery
 	book := models.Book{
 		Title:    bookInput.Title,
 		Category: bookInput.Category,
 		Price:    bookInput.Price,
-		Qty:      bookInput.Qty,
 	}
 
-	result := initializers.DB.Create(&book)
-	if result.Error != nil {
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&book).Error; err != nil {
+			return err
+		}
+
+		if bookInput.Qty != nil && *bookInput.Qty != 0 {
+			movement := models.StockMovement{
+				BookID:   uint(book.ID),
+				Type:     models.StockReceipt,
+				Quantity: *bookInput.Qty,
+				Reason:   models.StockInitialReason,
+				UserID:   authUserID(c),
+			}
+			if err := inventory.Move(tx, &movement); err != nil {
+				return err
+			}
+
+			book.Qty = movement.BalanceAfter
+		}
+
+		return recordAudit(tx, c, audit.ActionCreate, audit.ResourceBook, uint(book.ID), nil, book)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Cannot create book",
 		})
@@ -64,20 +90,47 @@ func CreateBook(c *gin.Context) {
 	})
 }
 
-// GetAll Book
+// ListBook gets the books matching the filters of the query string
 func ListBook(c *gin.Context) {
 	var allBook []models.Book
 
-	var filter models.BookFilter
-	if err := c.BindQuery(&filter); err != nil {
-		format_errors.InternalServerError(c)
+	page, ok := pageParams(c, "limit", 10)
+	if !ok {
 		return
 	}
 
-	result, err := pagination.Paginate(initializers.DB, filter.Page, filter.Limit, nil, &allBook)
-
+	// The pagination parameters are read with the filters, any other parameter is rejected
+	var filter struct {
+		models.BookFilter
+		pagination.CursorParams
+	}
+	scope, err := filters.Parse(&filter, c.Request.URL.Query())
 	if err != nil {
-		format_errors.InternalServerError(c)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// A range has to be open or have its minimum below its maximum
+	invalid := make(map[string]interface{})
+	if filter.PriceMin != nil && filter.PriceMax != nil && *filter.PriceMin > *filter.PriceMax {
+		invalid["PriceMin"] = "PriceMin must not be greater than PriceMax"
+	}
+
+	if filter.QtyMin != nil && filter.QtyMax != nil && *filter.QtyMin > *filter.QtyMax {
+		invalid["QtyMin"] = "QtyMin must not be greater than QtyMax"
+	}
+
+	if len(invalid) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": invalid,
+		})
+		return
+	}
+
+	result, ok := paginateList(c, page, filter.CursorParams, scope, &allBook)
+	if !ok {
 		return
 	}
 
@@ -102,6 +155,7 @@ func GetBook(c *gin.Context) {
 	}
 
 	// Return
+	helpers.SetETag(c, book.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"book": book,
 	})
@@ -137,6 +191,11 @@ func UpdateBook(c *gin.Context) {
 		return
 	}
 
+	// The client must have seen the latest version
+	if !helpers.CheckIfMatch(c, book.Version) {
+		return
+	}
+
 	// Name unique validation
 	if validations.IsUniqueValue("books", "title", bookInput.Title, book.ID) {
 		c.JSON(http.StatusConflict, gin.H{
@@ -148,23 +207,52 @@ func UpdateBook(c *gin.Context) {
 		return
 	}
 
+	// The stock is only changed by stock movements, the qty may be sent back unchanged
+	if bookInput.Qty != nil && *bookInput.Qty != book.Qty {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Qty": "The stock cannot be updated, post a stock movement instead",
+			},
+		})
+
+		return
+	}
+
 	updateBook := models.Book{
 		Title:    bookInput.Title,
 		Category: bookInput.Category,
 		Price:    bookInput.Price,
-		Qty:      bookInput.Qty,
+		Version:  book.Version + 1,
 	}
 
-	// Update the book record
-	result = initializers.DB.Model(&book).Updates(updateBook)
-	if err := result.Error; err != nil {
+	// Update the book record unless it was changed since it was read
+	before := book
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&book).Where("version = ?", before.Version).Updates(updateBook)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errRecordChanged
+		}
+
+		return recordAudit(tx, c, audit.ActionUpdate, audit.ResourceBook, uint(book.ID), before, book)
+	})
+	if errors.Is(err, errRecordChanged) {
+		helpers.PreconditionFailed(c)
+		return
+	}
+
+	if err != nil {
 		format_errors.InternalServerError(c)
 		return
 	}
 
 	// Return the book
+	helpers.SetETag(c, book.Version)
 	c.JSON(http.StatusOK, gin.H{
-		"book": updateBook,
+		"book": book,
 	})
 }
 
@@ -181,8 +269,18 @@ func DeleteBook(c *gin.Context) {
 		return
 	}
 
-	// Delete the post
-	initializers.DB.Delete(&book)
+	// Delete the book
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&book).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionDelete, audit.ResourceBook, uint(book.ID), book, nil)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
 
 	// Return the response
 	c.JSON(http.StatusOK, gin.H{
@@ -194,14 +292,60 @@ func DeleteBook(c *gin.Context) {
 func DeleteBookPermanent(c *gin.Context) {
 	// Get the id from request
 	id := c.Param("id")
+	var book models.Book
 
-	// Delete the post
-	result := initializers.DB.Unscoped().Delete(&models.Book{}, id)
+	// Find the book, it may already be soft deleted
+	result := initializers.DB.Unscoped().First(&book, id)
 	if err := result.Error; err != nil {
 		format_errors.RecordNotFound(c, err)
 		return
 	}
 
+	// Orders must keep showing the books they were placed for
+	var orderCount int64
+	if err := initializers.DB.Table("order_books").Where("book_id = ?", book.ID).Count(&orderCount).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if orderCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The book is used by orders and cannot be deleted permanently",
+		})
+		return
+	}
+
+	// The stock ledger is never rewritten, only a book whose single movement is its opening entry can go
+	var movements []models.StockMovement
+	if err := initializers.DB.Where("book_id = ?", book.ID).Order("id").Limit(2).Find(&movements).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if len(movements) > 1 || len(movements) == 1 && !movements[0].Opening() {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The book has stock movements and cannot be deleted permanently",
+		})
+		return
+	}
+
+	// Delete the book
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("book_id = ?", book.ID).Delete(&models.StockMovement{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(&book).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionForceDelete, audit.ResourceBook, uint(book.ID), book, nil)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
 	// Return the response
 	c.JSON(http.StatusOK, gin.H{
 		"message": "The book has been deleted permanently",
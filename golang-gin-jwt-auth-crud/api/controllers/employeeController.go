@@ -1,31 +1,46 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/audit"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/filters"
 	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
 )
 
-// GetAll Employee
+// ListEmployee gets the employees matching the filters of the query string
 func ListEmployee(c *gin.Context) {
 	var allEmployee []models.Employee
 
-	var filter models.EmployeeFilter
-	if err := c.BindQuery(&filter); err != nil {
-		format_errors.InternalServerError(c)
+	page, ok := pageParams(c, "limit", 10)
+	if !ok {
 		return
 	}
 
-	result, err := pagination.Paginate(initializers.DB, filter.Page, filter.Limit, nil, &allEmployee)
-
+	// The pagination parameters are read with the filters, any other parameter is rejected
+	var filter struct {
+		models.EmployeeFilter
+		pagination.CursorParams
+	}
+	scope, err := filters.Parse(&filter, c.Request.URL.Query())
 	if err != nil {
-		format_errors.InternalServerError(c)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	result, ok := paginateList(c, page, filter.CursorParams, scope, &allEmployee)
+	if !ok {
 		return
 	}
 
@@ -57,7 +72,7 @@ func CreateEmployee(c *gin.Context) {
 	}
 
 	//check email
-	if validations.IsExistValue("employees", "email", employeeInput.Email) {
+	if validations.IsUniqueValue("employees", "email", employeeInput.Email, 0) {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"validations": map[string]interface{}{
 				"Email": "The email is already used",
@@ -67,7 +82,7 @@ func CreateEmployee(c *gin.Context) {
 	}
 
 	//check phone number
-	if validations.IsExistValue("employees", "handphone", employeeInput.Handphone) {
+	if validations.IsUniqueValue("employees", "handphone", employeeInput.Handphone, 0) {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"validations": map[string]interface{}{
 				"Handphone": "The phone number is already used",
@@ -87,8 +102,14 @@ func CreateEmployee(c *gin.Context) {
 		MaritalStatus: employeeInput.MaritalStatus,
 	}
 
-	result := initializers.DB.Create(&employee)
-	if result.Error != nil {
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&employee).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionCreate, audit.ResourceEmployee, uint(employee.ID), nil, employee)
+	})
+	if err != nil {
 		format_errors.InternalServerError(c)
 		return
 	}
@@ -114,6 +135,7 @@ func GetEmployee(c *gin.Context) {
 	}
 
 	// Return the employee
+	helpers.SetETag(c, employee.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"employee": employee,
 	})
@@ -150,6 +172,11 @@ func UpdateEmployee(c *gin.Context) {
 		return
 	}
 
+	// The client must have seen the latest version
+	if !helpers.CheckIfMatch(c, employee.Version) {
+		return
+	}
+
 	// Check unique validation
 	if validations.IsUniqueValue("employees", "email", employeeInput.Email, employee.ID) {
 		c.JSON(http.StatusConflict, gin.H{
@@ -180,16 +207,35 @@ func UpdateEmployee(c *gin.Context) {
 		BirthPlace:    employeeInput.BirthPlace,
 		BirthDate:     employeeInput.BirthDate,
 		MaritalStatus: employeeInput.MaritalStatus,
+		Version:       employee.Version + 1,
 	}
 
-	// Update the book record
-	result = initializers.DB.Model(&employee).Updates(updateEmployee)
-	if err := result.Error; err != nil {
+	// Update the employee record unless it was changed since it was read
+	before := employee
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&employee).Where("version = ?", before.Version).Updates(updateEmployee)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errRecordChanged
+		}
+
+		return recordAudit(tx, c, audit.ActionUpdate, audit.ResourceEmployee, uint(employee.ID), before, employee)
+	})
+	if errors.Is(err, errRecordChanged) {
+		helpers.PreconditionFailed(c)
+		return
+	}
+
+	if err != nil {
 		format_errors.InternalServerError(c)
 		return
 	}
 
 	// Return the employee
+	helpers.SetETag(c, employee.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"employee": employee,
 	})
@@ -209,7 +255,17 @@ func DeleteEmployee(c *gin.Context) {
 	}
 
 	// Delete the employee
-	initializers.DB.Delete(&employee)
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&employee).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionDelete, audit.ResourceEmployee, uint(employee.ID), employee, nil)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
 
 	// Return the response
 	c.JSON(http.StatusOK, gin.H{
@@ -221,14 +277,27 @@ func DeleteEmployee(c *gin.Context) {
 func DeleteEmployeePermanent(c *gin.Context) {
 	// Get the id from request
 	id := c.Param("id")
+	var employee models.Employee
 
-	// Delete the employee
-	result := initializers.DB.Unscoped().Delete(&models.Employee{}, id)
-	if err := result.Error; err != nil {
+	// Find the employee, it may already be soft deleted
+	if err := initializers.DB.Unscoped().First(&employee, id).Error; err != nil {
 		format_errors.RecordNotFound(c, err)
 		return
 	}
 
+	// Delete the employee
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&employee).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionForceDelete, audit.ResourceEmployee, uint(employee.ID), employee, nil)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
 	// Return the response
 	c.JSON(http.StatusOK, gin.H{
 		"message": "The employee has been deleted permanently",
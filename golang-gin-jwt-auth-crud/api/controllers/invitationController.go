@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/mailer"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Signup modes set with SIGNUP_MODE
+const (
+	signupModeOpen     = "open"
+	signupModeInvite   = "invite"
+	signupModeDisabled = "disabled"
+)
+
+var errInvalidInvitation = errors.New("invalid invitation")
+
+// signupMode returns whether anybody, only invited people or nobody can sign up
+func signupMode() string {
+	return config.GetString("SIGNUP_MODE", signupModeOpen)
+}
+
+// findInvitation returns the pending invitation with the token
+func findInvitation(token string) (models.Invitation, error) {
+	var invitation models.Invitation
+
+	err := initializers.DB.Where("token_hash = ?", tokens.HashToken(token)).First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !invitation.Pending()) {
+		return invitation, errInvalidInvitation
+	}
+
+	return invitation, err
+}
+
+// acceptInvitation marks the invitation as used, the row is locked so it can only be used once
+func acceptInvitation(tx *gorm.DB, invitationID uint) error {
+	var invitation models.Invitation
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invitation, invitationID).Error
+	if err != nil {
+		return err
+	}
+
+	if !invitation.Pending() {
+		return errInvalidInvitation
+	}
+
+	return tx.Model(&invitation).Update("accepted_at", time.Now()).Error
+}
+
+// sendInvitationEmail mails the signup link with the invitation token
+func sendInvitationEmail(invitation models.Invitation, token string) error {
+	signupURL := config.GetString("INVITATION_URL", config.GetString("APP_URL", "http://localhost:3000")+"/signup")
+	link := fmt.Sprintf("%s?invitation_token=%s", signupURL, url.QueryEscape(token))
+
+	return mailer.Default.Send(mailer.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited",
+		Body: fmt.Sprintf("Hi,\n\nYou have been invited to create an account. Open the link below to sign up:\n\n%s\n\nThe invitation expires on %s.\n",
+			link, invitation.ExpiresAt.Format(time.RFC1123)),
+	})
+}
+
+// CreateInvitation invites an email address to sign up with a role
+func CreateInvitation(c *gin.Context) {
+	var invitationInput struct {
+		Email string `json:"email" binding:"required,email"`
+		Role  string `json:"role"`
+	}
+
+	if err := c.ShouldBindJSON(&invitationInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	// Inviting with another role than the default one needs the permission to assign roles
+	role := rbac.DefaultSignupRole()
+	if invitationInput.Role != "" && invitationInput.Role != role {
+		if !authUser.HasPermission(rbac.RolesAssign) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "You do not have permission to assign roles",
+			})
+			return
+		}
+
+		role = invitationInput.Role
+	}
+
+	if _, err := rbac.FindRoles(initializers.DB, []string{role}); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Role": err.Error(),
+			},
+		})
+		return
+	}
+
+	// Email unique validation
+	if validations.IsUniqueValue("users", "email", invitationInput.Email, 0) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Email": "The email is already exist!",
+			},
+		})
+		return
+	}
+
+	raw, hash, err := tokens.NewOpaqueToken()
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	invitation := models.Invitation{
+		Email:       invitationInput.Email,
+		Role:        role,
+		TokenHash:   hash,
+		ExpiresAt:   time.Now().Add(config.GetDuration("INVITATION_TTL", 7*24*time.Hour)),
+		InvitedByID: authUser.ID,
+	}
+
+	// A new invitation replaces the pending ones of the email
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Invitation{}).
+			Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.Email).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&invitation).Error
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// The invitation can be sent again by creating a new one if this fails
+	if err := sendInvitationEmail(invitation, raw); err != nil {
+		log.Println("Failed to send the invitation email:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invitation": invitation,
+	})
+}
+
+// ListInvitations lists the invitations, ?status=pending|accepted|revoked|expired filters them
+func ListInvitations(c *gin.Context) {
+	var invitations []models.Invitation
+
+	page, ok := pageParams(c, "perPage", 10)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	statusFunc := map[string]func(*gorm.DB) *gorm.DB{
+		"": func(query *gorm.DB) *gorm.DB {
+			return query
+		},
+		"pending": func(query *gorm.DB) *gorm.DB {
+			return query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+		},
+		"accepted": func(query *gorm.DB) *gorm.DB {
+			return query.Where("accepted_at IS NOT NULL")
+		},
+		"revoked": func(query *gorm.DB) *gorm.DB {
+			return query.Where("revoked_at IS NOT NULL")
+		},
+		"expired": func(query *gorm.DB) *gorm.DB {
+			return query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+		},
+	}
+
+	filter, ok := statusFunc[c.Query("status")]
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Status": "Status must be one of pending, accepted, revoked or expired",
+			},
+		})
+		return
+	}
+
+	// The newest invitations come first, the id keeps the pages stable
+	rawFunc := func(query *gorm.DB) *gorm.DB {
+		return filter(query).Order("created_at DESC, id DESC").Preload("InvitedBy", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, name, email")
+		})
+	}
+
+	result, err := pagination.Paginate(initializers.DB, page.Page, page.Limit, rawFunc, &invitations)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result": result,
+	})
+}
+
+// RevokeInvitation stops a pending invitation from being used
+func RevokeInvitation(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	var invitation models.Invitation
+	if err := initializers.DB.First(&invitation, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if invitation.AcceptedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The invitation has already been accepted",
+		})
+		return
+	}
+
+	if invitation.RevokedAt == nil {
+		if err := initializers.DB.Model(&invitation).Update("revoked_at", time.Now()).Error; err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invitation": invitation,
+	})
+}
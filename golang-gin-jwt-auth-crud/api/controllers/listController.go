@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// pageParams reads the page and the page size named limitParam from the query string. Invalid values
+// are answered with 422, false is returned then.
+func pageParams(c *gin.Context, limitParam string, defaultLimit int) (pagination.PageParams, bool) {
+	params, invalid := pagination.ParsePageParams(c.Query("page"), c.Query(limitParam), limitParam, defaultLimit)
+	if invalid != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": invalid,
+		})
+		return params, false
+	}
+
+	return params, true
+}
+
+// paginateList loads a page of the list, or the slice after the cursor when the request uses cursor
+// pagination. Errors are answered here, false is returned then.
+func paginateList(c *gin.Context, page pagination.PageParams, params pagination.CursorParams, rawFunc func(*gorm.DB) *gorm.DB, output interface{}) (interface{}, bool) {
+	if params.Pagination != "" && params.Pagination != "page" && params.Pagination != "cursor" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "pagination must be page or cursor",
+		})
+		return nil, false
+	}
+
+	if !params.UsesCursor() {
+		result, err := pagination.Paginate(initializers.DB, page.Page, page.Limit, rawFunc, output)
+		if err != nil {
+			format_errors.InternalServerError(c)
+			return nil, false
+		}
+
+		return result, true
+	}
+
+	result, err := pagination.PaginateCursor(initializers.DB, params.Cursor, page.Limit, params.WithTotal, rawFunc, output)
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid cursor, the sort must stay the same between requests",
+		})
+		return nil, false
+	}
+
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return nil, false
+	}
+
+	return result, true
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/throttle"
+	"github.com/gin-gonic/gin"
+)
+
+// loginRetryAfter returns how long the account or the client IP is still locked out
+func loginRetryAfter(c *gin.Context, email string) (time.Duration, error) {
+	accountWait, err := throttle.Accounts.RetryAfter(strings.ToLower(email))
+	if err != nil {
+		return 0, err
+	}
+
+	ipWait, err := throttle.IPs.RetryAfter(c.ClientIP())
+	if err != nil {
+		return 0, err
+	}
+
+	if ipWait > accountWait {
+		return ipWait, nil
+	}
+
+	return accountWait, nil
+}
+
+// tooManyLoginAttempts responds with 429 and the Retry-After header
+func tooManyLoginAttempts(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many failed login attempts, please try again later",
+		"retry_after": seconds,
+	})
+}
+
+// failLogin records a failed login for the account and the client IP and responds with the given error,
+// or with 429 when this failure locked one of them out
+func failLogin(c *gin.Context, email, errorMessage string) {
+	accountLockout, err := throttle.Accounts.Fail(strings.ToLower(email))
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	ipLockout, err := throttle.IPs.Fail(c.ClientIP())
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	lockout := accountLockout
+	if ipLockout > lockout {
+		lockout = ipLockout
+	}
+
+	if lockout > 0 {
+		tooManyLoginAttempts(c, lockout)
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": errorMessage,
+	})
+}
+
+// succeedLogin forgets the failed logins of the account and records the time of the login
+func succeedLogin(user models.User) {
+	if err := throttle.Accounts.Reset(strings.ToLower(user.Email)); err != nil {
+		log.Println("Failed to reset the login attempts:", err)
+	}
+
+	if err := initializers.DB.Model(&user).UpdateColumn("last_login_at", time.Now()).Error; err != nil {
+		log.Println("Failed to save the last login time:", err)
+	}
+}
+
+// UnlockUser removes the lockout of a user account, and of a client IP when one is given
+func UnlockUser(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	var unlockInput struct {
+		IP string `json:"ip"`
+	}
+
+	// The body is optional
+	_ = c.ShouldBindJSON(&unlockInput)
+
+	// Find the user
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if err := throttle.Accounts.Reset(strings.ToLower(user.Email)); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if unlockInput.IP != "" {
+		if err := throttle.IPs.Reset(unlockInput.IP); err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "The user has been unlocked",
+	})
+}
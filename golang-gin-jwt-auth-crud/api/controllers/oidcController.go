@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/oidc"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// oidcStateCookie keeps the state, nonce and PKCE verifier between the login and the callback
+const oidcStateCookie = "OIDCState"
+
+var (
+	errNoAccount         = errors.New("no account for the identity")
+	errUnverifiedAccount = errors.New("the account email is not verified")
+)
+
+// userForIdentity returns the user linked to the identity. An identity with a verified email is linked
+// to the user with that email, or to a new user when anybody can sign up.
+func userForIdentity(providerName string, identity oidc.Identity) (models.User, error) {
+	var user models.User
+	now := time.Now()
+
+	var link models.Identity
+	err := initializers.DB.Where("provider = ? AND subject = ?", providerName, identity.Subject).First(&link).Error
+	if err == nil {
+		if err := initializers.DB.First(&user, link.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return user, errNoAccount
+			}
+			return user, err
+		}
+
+		err = initializers.DB.Model(&link).Updates(map[string]interface{}{
+			"email":         identity.Email,
+			"last_login_at": now,
+		}).Error
+		return user, err
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, err
+	}
+
+	// Only an email address verified by the provider can be trusted to find the user
+	if identity.Email == "" || !identity.EmailVerified {
+		return user, errNoAccount
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("email = ?", identity.Email).First(&user).Error
+
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if signupMode() != signupModeOpen {
+				return errNoAccount
+			}
+
+			roles, err := rbac.FindRoles(tx, []string{rbac.DefaultSignupRole()})
+			if err != nil {
+				return err
+			}
+
+			name := identity.Name
+			if name == "" {
+				name, _, _ = strings.Cut(identity.Email, "@")
+			}
+
+			// The user has no password and can only sign in with the provider until one is set
+			user = models.User{
+				Name:            name,
+				Email:           identity.Email,
+				EmailVerifiedAt: &now,
+				Roles:           roles,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case user.EmailVerifiedAt == nil && user.Password != "":
+			// Somebody else may have signed up with the address, the owner must verify it first
+			return errUnverifiedAccount
+		case user.EmailVerifiedAt == nil:
+			// Users created by an admin have no password yet, the provider proves the address
+			if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.Identity{
+			UserID:      user.ID,
+			Provider:    providerName,
+			Subject:     identity.Subject,
+			Email:       identity.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+
+	return user, err
+}
+
+// OIDCLogin redirects the user to the login page of the identity provider
+func OIDCLogin(c *gin.Context) {
+	provider, err := oidc.Find(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Unknown identity provider",
+		})
+		return
+	}
+
+	state, err := oidc.NewState()
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	nonce, err := oidc.NewState()
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Println("Failed to reach the identity provider:", err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "The identity provider is not available",
+		})
+		return
+	}
+
+	// The signed cookie ties the callback to this browser
+	ttl := config.GetDuration("OIDC_LOGIN_TTL", 10*time.Minute)
+	stateToken, _, err := tokens.GenerateTypedToken(tokens.TypeOIDCState, 0, ttl, jwt.MapClaims{
+		"provider": provider.Name,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, stateToken, int(ttl.Seconds()), "/api/oidc", "", false, true)
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback finishes the login at the identity provider and logs the user in with the usual cookies
+func OIDCCallback(c *gin.Context) {
+	provider, err := oidc.Find(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Unknown identity provider",
+		})
+		return
+	}
+
+	// The state cookie can only be used once
+	stateToken, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "/api/oidc", "", false, true)
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "The identity provider refused the login: " + providerError,
+		})
+		return
+	}
+
+	claims, err := tokens.ParseTypedToken(tokens.TypeOIDCState, stateToken)
+	state, _ := claims["state"].(string)
+
+	if err != nil || claims["provider"] != provider.Name || !oidc.SameState(c.Query("state"), state) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid or expired login, please try again",
+		})
+		return
+	}
+
+	verifier, _ := claims["verifier"].(string)
+	nonce, _ := claims["nonce"].(string)
+
+	identity, err := provider.Exchange(c.Request.Context(), c.Query("code"), verifier, nonce)
+	if err != nil {
+		log.Println("Failed to verify the identity provider login:", err)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "The login could not be verified",
+		})
+		return
+	}
+
+	user, err := userForIdentity(provider.Name, identity)
+	switch {
+	case errors.Is(err, errNoAccount):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "There is no account for this login",
+		})
+		return
+	case errors.Is(err, errUnverifiedAccount):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Please verify your email address before signing in with " + provider.Name,
+		})
+		return
+	case err != nil:
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// Disabled accounts cannot log in
+	if rejectDisabledUser(c, user) {
+		return
+	}
+
+	redirectURL := config.GetString("OIDC_LOGIN_REDIRECT_URL", "")
+
+	// The provider does not replace the second factor, users with two-factor authentication exchange
+	// this token and a code at /api/login/2fa like after a password login
+	if user.TOTPEnabledAt != nil {
+		mfaToken, err := issueMFAPendingToken(user.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to create token",
+			})
+			return
+		}
+
+		// The fragment is not sent to any server, only the frontend reads it
+		if redirectURL != "" {
+			c.Redirect(http.StatusFound, redirectURL+"#mfa_token="+url.QueryEscape(mfaToken))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		})
+		return
+	}
+
+	succeedLogin(user)
+
+	// Issue the access and refresh tokens as cookies
+	if _, err := issueSession(c, user.ID, false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to create token",
+		})
+		return
+	}
+
+	// Browsers are sent back to the frontend
+	if redirectURL != "" {
+		c.Redirect(http.StatusFound, redirectURL)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
@@ -1,19 +1,100 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/audit"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/filters"
 	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/inventory"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// preloadOrderRelations loads the books and the employee of orders, including soft deleted ones
+// so that old orders still show what was ordered
+func preloadOrderRelations(query *gorm.DB) *gorm.DB {
+	return query.Preload("Books", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id, title, price, category, qty, deleted_at")
+	}).Preload("Employee", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id, name, deleted_at")
+	})
+}
+
+// orderBooks loads the books of an order request, every id must belong to a book. Soft deleted books
+// are only accepted when the order already contains them. Invalid ids are answered with 422, false is returned then.
+func orderBooks(c *gin.Context, ids []int, current []uint) ([]models.Book, bool) {
+	var books []models.Book
+	err := initializers.DB.Unscoped().Where("id IN ?", ids).Where("deleted_at IS NULL OR id IN ?", current).Find(&books).Error
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return nil, false
+	}
+
+	// An order contains a book once, repeated ids are ignored
+	unique := make(map[int]bool)
+	for _, id := range ids {
+		unique[id] = true
+	}
+
+	if len(books) != len(unique) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"BookID": "Every book of the order must exist",
+			},
+		})
+		return nil, false
+	}
+
+	return books, true
+}
+
+// bookIDs returns the ids of the books
+func bookIDs(books []models.Book) []uint {
+	ids := make([]uint, len(books))
+	for i, book := range books {
+		ids[i] = uint(book.ID)
+	}
+
+	return ids
+}
+
+// orderTotal sums the prices of the books
+func orderTotal(books []models.Book) int {
+	total := 0
+	for _, book := range books {
+		total += book.Price
+	}
+
+	return total
+}
+
+// idsMissingFrom returns the ids that are not in other
+func idsMissingFrom(ids, other []uint) []uint {
+	present := make(map[uint]bool, len(other))
+	for _, id := range other {
+		present[id] = true
+	}
+
+	var missing []uint
+	for _, id := range ids {
+		if !present[id] {
+			missing = append(missing, id)
+		}
+	}
+
+	return missing
+}
+
 // CreateOrder creates a order
 func CreateOrder(c *gin.Context) {
 	// Get input from request
@@ -30,22 +111,44 @@ func CreateOrder(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
+		return
+	}
+
+	books, ok := orderBooks(c, orderInput.BookID, nil)
+	if !ok {
+		return
 	}
 
 	//populate total price
-	var totalPrice int
-	initializers.DB.Table("book").Where("id IN (?)", orderInput.BookID).Select("SUM(total_price)").Scan(&totalPrice)
 	order := models.Order{
 		EmployeeID: orderInput.EmployeeID,
 		OrderDate:  time.Now().Format("2006-01-02"),
-		TotalPrice: totalPrice,
+		Books:      books,
+		TotalPrice: orderTotal(books),
 	}
 
-	result := initializers.DB.Create(&order)
-	if result.Error != nil {
-		format_errors.InternalServerError(c)
+	// The order and the sale of its books are saved together, the books themselves are not written
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Books.*").Create(&order).Error; err != nil {
+			return err
+		}
+
+		if err := inventory.MoveOrderBooks(tx, uint(order.ID), bookIDs(books), models.StockSale, authUserID(c)); err != nil {
+			return err
+		}
+
+		// Reload the books with their new stock
+		if err := preloadOrderRelations(tx).First(&order, order.ID).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionCreate, audit.ResourceOrder, uint(order.ID), nil, order)
+	})
+	if err != nil {
+		stockError(c, err)
 		return
 	}
+
 	// Return the order
 	c.JSON(http.StatusOK, gin.H{
 		"order": order,
@@ -56,23 +159,41 @@ func CreateOrder(c *gin.Context) {
 func ListOrders(c *gin.Context) {
 	// Get all the orders
 	var orders []models.Order
-	var filter models.OrderFilter
-	if err := c.BindQuery(&filter); err != nil {
-		format_errors.InternalServerError(c)
+	page, ok := pageParams(c, "limit", 10)
+	if !ok {
 		return
 	}
 
-	preloadFunc := func(query *gorm.DB) *gorm.DB {
-		return query.Preload("Book", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, title, price, category, qty")
-		}).Preload("Employee", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, name")
+	// The pagination parameters are read with the filters, any other parameter is rejected
+	var filter struct {
+		models.OrderFilter
+		pagination.CursorParams
+	}
+	scope, err := filters.Parse(&filter, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
 		})
+		return
 	}
 
-	result, err := pagination.Paginate(initializers.DB, filter.Page, filter.Limit, preloadFunc, &orders)
-	if err != nil {
-		format_errors.InternalServerError(c)
+	rawFunc := func(query *gorm.DB) *gorm.DB {
+		// Orders containing any of the books
+		if len(filter.BookIDS) > 0 {
+			query = query.Where("id IN (?)", initializers.DB.Table("order_books").Select("order_id").Where("book_id IN ?", filter.BookIDS))
+		}
+
+		// The search looks at the name and email of the employee
+		if filter.Search != "" {
+			pattern := filters.LikePattern(filter.Search)
+			query = query.Where("employee_id IN (?)", initializers.DB.Unscoped().Model(&models.Employee{}).Select("id").Where("name ILIKE ? OR email ILIKE ?", pattern, pattern))
+		}
+
+		return preloadOrderRelations(scope(query))
+	}
+
+	result, ok := paginateList(c, page, filter.CursorParams, rawFunc, &orders)
+	if !ok {
 		return
 	}
 
@@ -88,11 +209,7 @@ func GetOrder(c *gin.Context) {
 
 	// Find the order
 	var order models.Order
-	result := initializers.DB.Preload("Book", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id, title, price, category, qty")
-	}).Preload("Employee", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id, name")
-	}).First(&order, id)
+	result := preloadOrderRelations(initializers.DB).First(&order, id)
 
 	if err := result.Error; err != nil {
 		format_errors.RecordNotFound(c, err)
@@ -100,6 +217,7 @@ func GetOrder(c *gin.Context) {
 	}
 
 	// Return the order
+	helpers.SetETag(c, order.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"order": order,
 	})
@@ -129,32 +247,88 @@ func UpdateOrder(c *gin.Context) {
 
 	// Find the order by id
 	var order models.Order
-	result := initializers.DB.First(&order, id)
+	result := preloadOrderRelations(initializers.DB).First(&order, id)
 	if err := result.Error; err != nil {
 		format_errors.RecordNotFound(c, err)
 		return
 	}
-	//recalculate total price
-	var totalPrice int
-	initializers.DB.Table("book").Where("id IN (?)", orderInput.BookID).Select("SUM(total_price)").Scan(&totalPrice)
 
-	// Prepare data to update
+	// The client must have seen the latest version
+	if !helpers.CheckIfMatch(c, order.Version) {
+		return
+	}
+
+	current := bookIDs(order.Books)
+	books, ok := orderBooks(c, orderInput.BookID, current)
+	if !ok {
+		return
+	}
+
+	// Removed books go back to the stock and added books are sold
+	updated := bookIDs(books)
+	removed, added := idsMissingFrom(current, updated), idsMissingFrom(updated, current)
+
+	//recalculate total price
 	updateOrder := models.Order{
 		EmployeeID: orderInput.EmployeeID,
 		OrderDate:  time.Now().Format("2006-01-02"),
-		TotalPrice: totalPrice,
+		TotalPrice: orderTotal(books),
+		Version:    order.Version + 1,
 	}
 
-	// Update the order
-	finalResult := initializers.DB.Model(&order).Updates(&updateOrder)
-	if finalResult.Error != nil {
-		format_errors.InternalServerError(c)
+	// Update the order unless it was changed since it was read
+	before := order
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&order).Omit(clause.Associations).Where("version = ?", before.Version).Updates(&updateOrder)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errRecordChanged
+		}
+
+		if len(removed) > 0 {
+			if err := tx.Exec("DELETE FROM order_books WHERE order_id = ? AND book_id IN ?", order.ID, removed).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, bookID := range added {
+			if err := tx.Exec("INSERT INTO order_books (order_id, book_id) VALUES (?, ?)", order.ID, bookID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := inventory.MoveOrderBooks(tx, uint(order.ID), removed, models.StockReturn, authUserID(c)); err != nil {
+			return err
+		}
+
+		if err := inventory.MoveOrderBooks(tx, uint(order.ID), added, models.StockSale, authUserID(c)); err != nil {
+			return err
+		}
+
+		// Reload the books of the order
+		if err := preloadOrderRelations(tx).First(&order, order.ID).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionUpdate, audit.ResourceOrder, uint(order.ID), before, order)
+	})
+	if errors.Is(err, errRecordChanged) {
+		helpers.PreconditionFailed(c)
+		return
+	}
+
+	if err != nil {
+		stockError(c, err)
 		return
 	}
 
 	// Return the order
+	helpers.SetETag(c, order.Version)
 	c.JSON(http.StatusOK, gin.H{
-		"order": updateOrder,
+		"order": order,
 	})
 }
 
@@ -164,14 +338,28 @@ func DeleteOrder(c *gin.Context) {
 	id := c.Param("id")
 	var order models.Order
 
-	result := initializers.DB.First(&order, id)
+	result := preloadOrderRelations(initializers.DB).First(&order, id)
 	if err := result.Error; err != nil {
 		format_errors.RecordNotFound(c, err)
 		return
 	}
 
-	// Delete the order
-	initializers.DB.Delete(&order)
+	// Delete the order and return its books to the stock
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&order).Error; err != nil {
+			return err
+		}
+
+		if err := inventory.MoveOrderBooks(tx, uint(order.ID), bookIDs(order.Books), models.StockReturn, authUserID(c)); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionDelete, audit.ResourceOrder, uint(order.ID), order, nil)
+	})
+	if err != nil {
+		stockError(c, err)
+		return
+	}
 
 	// Return response
 	c.JSON(http.StatusOK, gin.H{
@@ -186,13 +374,33 @@ func PermanentlyDeleteOrder(c *gin.Context) {
 	var order models.Order
 
 	// Find the order
-	if err := initializers.DB.Unscoped().First(&order, id).Error; err != nil {
+	if err := preloadOrderRelations(initializers.DB.Unscoped()).First(&order, id).Error; err != nil {
 		format_errors.RecordNotFound(c, err)
 		return
 	}
 
-	// Delete the order
-	initializers.DB.Unscoped().Delete(&order)
+	// Delete the order, the books of an order that was not soft deleted yet go back to the stock
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if !order.DeletedAt.Valid {
+			if err := inventory.MoveOrderBooks(tx, uint(order.ID), bookIDs(order.Books), models.StockReturn, authUserID(c)); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("DELETE FROM order_books WHERE order_id = ?", order.ID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(&order).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionForceDelete, audit.ResourceOrder, uint(order.ID), order, nil)
+	})
+	if err != nil {
+		stockError(c, err)
+		return
+	}
 
 	// Return response
 	c.JSON(http.StatusOK, gin.H{
@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/mailer"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// passwordResetLink creates a one time reset token for the user valid for ttl and returns the link.
+// Earlier unused tokens of the user stop working.
+func passwordResetLink(user models.User, ttl time.Duration) (string, error) {
+	raw, hash, err := tokens.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.PasswordReset{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PasswordReset{
+			UserID:    user.ID,
+			TokenHash: hash,
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resetURL := config.GetString("PASSWORD_RESET_URL", config.GetString("APP_URL", "http://localhost:3000")+"/reset-password")
+
+	return fmt.Sprintf("%s?token=%s", resetURL, url.QueryEscape(raw)), nil
+}
+
+// sendPasswordResetEmail mails a password reset link to the user
+func sendPasswordResetEmail(user models.User) error {
+	ttl := config.GetDuration("PASSWORD_RESET_TTL", time.Hour)
+
+	link, err := passwordResetLink(user, ttl)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Default.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to choose a new password:\n\n%s\n\nThe link expires in %s. If you did not ask for this, you can ignore this email.\n",
+			user.Name, link, ttl),
+	})
+}
+
+// ForgotPassword sends a password reset link, the response does not reveal whether the email exists
+func ForgotPassword(c *gin.Context) {
+	var emailInput struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&emailInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	initializers.DB.First(&user, "email = ?", emailInput.Email)
+
+	if user.ID != 0 {
+		if err := sendPasswordResetEmail(user); err != nil {
+			log.Println("Failed to send the password reset email:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the email exists, a password reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password with a reset token and logs the user out everywhere
+func ResetPassword(c *gin.Context) {
+	var resetInput struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&resetInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// The new password must follow the password policy
+	if errs := validations.ValidatePassword(resetInput.Password); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Password": errs,
+			},
+		})
+		return
+	}
+
+	// Hash the password
+	hashPassword, err := helpers.HashPassword(resetInput.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to hash password",
+		})
+		return
+	}
+
+	errInvalidToken := errors.New("invalid reset token")
+	var reset models.PasswordReset
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the token so it can only be used once
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL", tokens.HashToken(resetInput.Token)).
+			First(&reset)
+
+		if err := result.Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidToken
+			}
+			return err
+		}
+
+		if time.Now().After(reset.ExpiresAt) {
+			return errInvalidToken
+		}
+
+		now := time.Now()
+		if err := tx.Model(&reset).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		// Set the new password, receiving the email also proves the address
+		return tx.Model(&models.User{}).Where("id = ?", reset.UserID).Updates(map[string]interface{}{
+			"password":          hashPassword,
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", now),
+		}).Error
+	})
+
+	if errors.Is(err, errInvalidToken) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid or expired reset token",
+		})
+		return
+	}
+
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// Log out every session, the old password may have been compromised
+	if err := tokens.RevokeAllSessions(initializers.DB, reset.UserID); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Your password has been reset, please log in again",
+	})
+}
+
+// ChangePassword changes the password of the logged in user and logs out the other sessions
+func ChangePassword(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var passwordInput struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+		// TokenInBody returns the new tokens in the response instead of cookies
+		TokenInBody bool `json:"token_in_body"`
+	}
+
+	if err := c.ShouldBindJSON(&passwordInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Find the user
+	var user models.User
+	if err := initializers.DB.First(&user, authUser.ID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// Compare the current password with user hashed password
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passwordInput.CurrentPassword)) != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"CurrentPassword": "The current password is incorrect",
+			},
+		})
+		return
+	}
+
+	// The new password must follow the password policy and differ from the current one
+	errs := validations.ValidatePassword(passwordInput.NewPassword)
+	if passwordInput.NewPassword == passwordInput.CurrentPassword {
+		errs = append(errs, "The new password must be different from the current password")
+	}
+
+	if len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"NewPassword": errs,
+			},
+		})
+		return
+	}
+
+	// Hash the password
+	hashPassword, err := helpers.HashPassword(passwordInput.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to hash password",
+		})
+		return
+	}
+
+	if err := initializers.DB.Model(&user).Update("password", hashPassword).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// Log out every session, then give this one new tokens so the user stays logged in here
+	if err := tokens.RevokeAllSessions(initializers.DB, user.ID); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	response, err := issueSession(c, user.ID, passwordInput.TokenInBody)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	response["message"] = "Your password has been changed, your other sessions have been logged out"
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/audit"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// ListRoles returns every role with its permissions
+func ListRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := initializers.DB.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles": roles,
+	})
+}
+
+// ListPermissions returns every permission that can be granted to a role
+func ListPermissions(c *gin.Context) {
+	var permissions []models.Permission
+	if err := initializers.DB.Order("name").Find(&permissions).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"permissions": permissions,
+	})
+}
+
+// CreateRole creates a role with the given permissions
+func CreateRole(c *gin.Context) {
+	var roleInput struct {
+		Name        string   `json:"name" binding:"required,min=2,max=100"`
+		Description string   `json:"description" binding:"max=255"`
+		Permissions []string `json:"permissions" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&roleInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Name unique validation
+	if validations.IsUniqueValue("roles", "name", roleInput.Name, 0) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Name": "The role is already exist!",
+			},
+		})
+		return
+	}
+
+	// Every permission must be known
+	var permissions []models.Permission
+	if err := initializers.DB.Where("name IN ?", roleInput.Permissions).Find(&permissions).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if len(permissions) != len(roleInput.Permissions) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Permissions": "One or more permissions do not exist",
+			},
+		})
+		return
+	}
+
+	role := models.Role{
+		Name:        roleInput.Name,
+		Description: roleInput.Description,
+		Permissions: permissions,
+	}
+
+	if err := initializers.DB.Create(&role).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"role": role,
+	})
+}
+
+// roleNames returns the names of the roles, the audit log only keeps the names
+func roleNames(roles []models.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+
+	return names
+}
+
+// AssignUserRoles replaces the roles of a user
+func AssignUserRoles(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	var rolesInput struct {
+		Roles []string `json:"roles" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&rolesInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Find the user with the current roles
+	var user models.User
+	if err := initializers.DB.Preload("Roles").First(&user, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	roles, err := rbac.FindRoles(initializers.DB, rolesInput.Roles)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Roles": err.Error(),
+			},
+		})
+		return
+	}
+
+	// Replace the roles
+	before := map[string]interface{}{"roles": roleNames(user.Roles)}
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Association("Roles").Replace(roles); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionUpdate, audit.ResourceUser, user.ID, before, map[string]interface{}{"roles": roleNames(roles)})
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
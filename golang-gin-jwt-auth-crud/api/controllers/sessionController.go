@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// sessionResponse is a session with a flag for the one making the request
+type sessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// currentSessionID returns the session of the access token of the request, 0 when there is none
+func currentSessionID(c *gin.Context) uint {
+	if claims, ok := c.Get("accessClaims"); ok {
+		return tokens.SessionID(claims.(jwt.MapClaims))
+	}
+
+	return 0
+}
+
+// ListSessions lists the devices the logged in user is logged in on
+func ListSessions(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	// Sessions end when they are revoked or their refresh token could have expired
+	var sessions []models.Session
+	err := initializers.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND last_seen_at > ?", authUser.ID, time.Now().Add(-tokens.RefreshTokenTTL())).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	current := currentSessionID(c)
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{Session: session, Current: session.ID == current})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": response,
+	})
+}
+
+// RevokeSession logs the logged in user out on one device
+func RevokeSession(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	// Get the id from url
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		format_errors.RecordNotFound(c, gorm.ErrRecordNotFound)
+		return
+	}
+
+	err = tokens.RevokeSession(initializers.DB, authUser.ID, uint(id))
+	if errors.Is(err, tokens.ErrSessionNotFound) {
+		format_errors.RecordNotFound(c, gorm.ErrRecordNotFound)
+		return
+	}
+
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// Revoking the session of this request is a logout
+	if uint(id) == currentSessionID(c) {
+		clearAuthCookies(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "The session has been revoked",
+	})
+}
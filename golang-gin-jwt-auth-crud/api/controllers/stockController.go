@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/inventory"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// stockError answers a failed stock movement, a lack of stock is a conflict with the current balance
+func stockError(c *gin.Context, err error) {
+	var insufficient *inventory.InsufficientStockError
+	if errors.As(err, &insufficient) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Not enough stock of " + insufficient.Title,
+			"book_id":   insufficient.BookID,
+			"available": insufficient.Available,
+		})
+		return
+	}
+
+	format_errors.InternalServerError(c)
+}
+
+// CreateStockMovement posts a receipt, a return or a manual adjustment of the stock of a book
+func CreateStockMovement(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	var input models.StockMovementRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	quantity, err := inventory.SignedQuantity(input.Type, input.Quantity)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Quantity": "Receipts and returns need a positive quantity, adjustments a quantity other than zero",
+			},
+		})
+		return
+	}
+
+	// Find the book
+	var book models.Book
+	if err := initializers.DB.First(&book, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	movement := models.StockMovement{
+		BookID:   uint(book.ID),
+		Type:     input.Type,
+		Quantity: quantity,
+		Reason:   input.Reason,
+		UserID:   authUserID(c),
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		return inventory.Move(tx, &movement)
+	})
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"movement": movement,
+		"qty":      movement.BalanceAfter,
+	})
+}
+
+// ListStockMovements gets the stock movements of a book, newest first. ledger_balance is the sum of all
+// the movements and equals qty while the stock is only changed through the ledger.
+func ListStockMovements(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	page, ok := pageParams(c, "perPage", 20)
+	if !ok {
+		return
+	}
+
+	// Find the book
+	var book models.Book
+	if err := initializers.DB.First(&book, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	balance, err := inventory.Balance(initializers.DB, uint(book.ID))
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	var movements []models.StockMovement
+	rawFunc := func(query *gorm.DB) *gorm.DB {
+		return query.Where("book_id = ?", book.ID).Order("id desc").Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, name, email")
+		})
+	}
+
+	result, err := pagination.Paginate(initializers.DB, page.Page, page.Limit, rawFunc, &movements)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"book_id":        book.ID,
+		"qty":            book.Qty,
+		"ledger_balance": balance,
+		"result":         result,
+	})
+}
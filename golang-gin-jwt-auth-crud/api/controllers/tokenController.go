@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	accessTokenCookie  = "Authorization"
+	refreshTokenCookie = "RefreshToken"
+)
+
+// issueSession records a new session of the user on this device and creates its access and refresh tokens.
+// The tokens are set as cookies, or returned in the response body when tokenInBody is set.
+func issueSession(c *gin.Context, userID uint, tokenInBody bool) (gin.H, error) {
+	session, refreshToken, err := tokens.StartSession(initializers.DB, userID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresAt, err := tokens.GenerateSessionAccessToken(userID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliverTokens(c, accessToken, expiresAt, refreshToken, tokenInBody), nil
+}
+
+// deliverTokens sets the tokens as cookies or returns them as the response body for clients that cannot use cookies
+func deliverTokens(c *gin.Context, accessToken string, expiresAt time.Time, refreshToken string, tokenInBody bool) gin.H {
+	if !tokenInBody {
+		setAuthCookies(c, accessToken, refreshToken)
+		return gin.H{}
+	}
+
+	return gin.H{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(time.Until(expiresAt).Seconds()),
+		"refresh_token": refreshToken,
+	}
+}
+
+// setAuthCookies sends the access and refresh tokens back as http only cookies
+func setAuthCookies(c *gin.Context, accessToken, refreshToken string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(accessTokenCookie, accessToken, int(tokens.AccessTokenTTL().Seconds()), "", "", false, true)
+	c.SetCookie(refreshTokenCookie, refreshToken, int(tokens.RefreshTokenTTL().Seconds()), "/api", "", false, true)
+}
+
+// clearAuthCookies removes the access and refresh token cookies
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie(accessTokenCookie, "", -1, "", "", false, true)
+	c.SetCookie(refreshTokenCookie, "", -1, "/api", "", false, true)
+}
+
+// refreshTokenFromRequest returns the refresh token from the cookie or the JSON body,
+// and whether it was sent in the body
+func refreshTokenFromRequest(c *gin.Context) (string, bool) {
+	if refreshToken, err := c.Cookie(refreshTokenCookie); err == nil && refreshToken != "" {
+		return refreshToken, false
+	}
+
+	var tokenInput struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if c.ShouldBindJSON(&tokenInput) != nil || tokenInput.RefreshToken == "" {
+		return "", false
+	}
+
+	return tokenInput.RefreshToken, true
+}
+
+// RefreshToken rotates the refresh token and issues a new access token
+func RefreshToken(c *gin.Context) {
+	// Get the refresh token from the cookie or the request body
+	refreshToken, tokenInBody := refreshTokenFromRequest(c)
+	if refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Refresh token is required",
+		})
+		return
+	}
+
+	// Rotate the refresh token
+	next, newRefreshToken, err := tokens.RotateRefreshToken(initializers.DB, refreshToken)
+	if err != nil {
+		clearAuthCookies(c)
+
+		switch {
+		case errors.Is(err, tokens.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Refresh token reuse detected, please log in again",
+			})
+		case errors.Is(err, tokens.ErrInvalidRefreshToken):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid or expired refresh token",
+			})
+		default:
+			format_errors.InternalServerError(c)
+		}
+		return
+	}
+
+	// Disabled accounts cannot renew their tokens, even when their sessions were not revoked
+	var user models.User
+	if err := initializers.DB.Select("id, status").First(&user, next.UserID).Error; err != nil {
+		clearAuthCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or expired refresh token",
+		})
+		return
+	}
+
+	if user.Status == models.UserStatusDisabled {
+		clearAuthCookies(c)
+		rejectDisabledUser(c, user)
+		return
+	}
+
+	// Refresh tokens issued before sessions were recorded have no session
+	var sessionID uint
+	session, err := tokens.FindSessionByFamily(initializers.DB, next.FamilyID)
+
+	switch {
+	case err == nil:
+		sessionID = session.ID
+		if err := tokens.TouchSession(initializers.DB, session, c.ClientIP()); err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+	case !errors.Is(err, tokens.ErrSessionNotFound):
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// Issue a new access token, delivered the same way the refresh token came in
+	accessToken, expiresAt, err := tokens.GenerateSessionAccessToken(next.UserID, sessionID)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliverTokens(c, accessToken, expiresAt, newRefreshToken, tokenInBody))
+}
+
+// JWKS publishes the public keys used to sign tokens so other services can verify them
+func JWKS(c *gin.Context) {
+	if tokens.Keys == nil {
+		c.JSON(http.StatusOK, gin.H{"keys": []interface{}{}})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, tokens.Keys.JWKS())
+}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/audit"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/inventory"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// onlyTrashed limits a query to soft deleted records
+func onlyTrashed(query *gorm.DB) *gorm.DB {
+	return query.Unscoped().Where("deleted_at IS NOT NULL")
+}
+
+// listTrashed responds with a page of the soft deleted records, output is a pointer to a slice of the model
+func listTrashed(c *gin.Context, output interface{}) {
+	page, ok := pageParams(c, "perPage", 10)
+	if !ok {
+		return
+	}
+
+	result, err := pagination.Paginate(initializers.DB, page.Page, page.Limit, onlyTrashed, output)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result": result,
+	})
+}
+
+// ListTrashedUsers gets the soft deleted users
+func ListTrashedUsers(c *gin.Context) {
+	var users []models.User
+	listTrashed(c, &users)
+}
+
+// ListTrashedEmployees gets the soft deleted employees
+func ListTrashedEmployees(c *gin.Context) {
+	var employees []models.Employee
+	listTrashed(c, &employees)
+}
+
+// ListTrashedBooks gets the soft deleted books
+func ListTrashedBooks(c *gin.Context) {
+	var books []models.Book
+	listTrashed(c, &books)
+}
+
+// ListTrashedOrders gets the soft deleted orders
+func ListTrashedOrders(c *gin.Context) {
+	var orders []models.Order
+	listTrashed(c, &orders)
+}
+
+// RestoreUser restores a soft deleted user
+func RestoreUser(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	// Find the deleted user
+	var user models.User
+	if err := onlyTrashed(initializers.DB).First(&user, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// The email may have been taken while the user was deleted
+	if validations.IsUniqueValue("users", "email", user.Email, int(user.ID)) {
+		c.JSON(http.StatusConflict, gin.H{
+			"validations": map[string]interface{}{
+				"Email": "The email is already used by another user",
+			},
+		})
+		return
+	}
+
+	// Restore the user
+	before := user
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&user).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionRestore, audit.ResourceUser, uint(user.ID), before, user)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+// RestoreEmployee restores a soft deleted employee
+func RestoreEmployee(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	// Find the deleted employee
+	var employee models.Employee
+	if err := onlyTrashed(initializers.DB).First(&employee, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// The unique values may have been taken while the employee was deleted
+	conflicts := make(map[string]interface{})
+	if validations.IsUniqueValue("employees", "name", employee.Name, employee.ID) {
+		conflicts["Name"] = "The name is already used by another employee"
+	}
+
+	if validations.IsUniqueValue("employees", "email", employee.Email, employee.ID) {
+		conflicts["Email"] = "The email is already used by another employee"
+	}
+
+	if employee.Handphone != "" && validations.IsUniqueValue("employees", "handphone", employee.Handphone, employee.ID) {
+		conflicts["Handphone"] = "The phone number is already used by another employee"
+	}
+
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"validations": conflicts,
+		})
+		return
+	}
+
+	// Restore the employee
+	before := employee
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&employee).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionRestore, audit.ResourceEmployee, uint(employee.ID), before, employee)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"employee": employee,
+	})
+}
+
+// RestoreBook restores a soft deleted book
+func RestoreBook(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	// Find the deleted book
+	var book models.Book
+	if err := onlyTrashed(initializers.DB).First(&book, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// The title may have been taken while the book was deleted
+	if validations.IsUniqueValue("books", "title", book.Title, book.ID) {
+		c.JSON(http.StatusConflict, gin.H{
+			"validations": map[string]interface{}{
+				"Title": "The title is already used by another book",
+			},
+		})
+		return
+	}
+
+	// Restore the book
+	before := book
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&book).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionRestore, audit.ResourceBook, uint(book.ID), before, book)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"book": book,
+	})
+}
+
+// RestoreOrder restores a soft deleted order
+func RestoreOrder(c *gin.Context) {
+	// Get the id from url
+	id := c.Param("id")
+
+	// Find the deleted order
+	var order models.Order
+	if err := preloadOrderRelations(onlyTrashed(initializers.DB)).First(&order, id).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	// Restore the order, its books are sold again
+	before := order
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&order).Omit(clause.Associations).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		if err := inventory.MoveOrderBooks(tx, uint(order.ID), bookIDs(order.Books), models.StockSale, authUserID(c)); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionRestore, audit.ResourceOrder, uint(order.ID), before, order)
+	})
+	if err != nil {
+		stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order": order,
+	})
+}
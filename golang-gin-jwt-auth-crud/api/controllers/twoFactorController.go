@@ -0,0 +1,379 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/totp"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+// generateRecoveryCodes replaces the recovery codes of the user and returns the new codes, only their hashes are stored
+func generateRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		// e.g. "k3j5x-q2m7a"
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+		code := raw[:5] + "-" + raw[5:]
+
+		codes = append(codes, code)
+		rows = append(rows, models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	return tokens.HashToken(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
+
+// verifySecondFactor checks a TOTP code or, when no code is given, a recovery code.
+// A TOTP code can only be used once and a recovery code is used up.
+func verifySecondFactor(user models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+
+		// Only accept steps newer than the last used one so an observed code cannot be replayed
+		result := initializers.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+
+		return result.RowsAffected == 1, result.Error
+	}
+
+	if recoveryCode != "" {
+		result := initializers.DB.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(recoveryCode)).
+			Update("used_at", time.Now())
+
+		return result.RowsAffected == 1, result.Error
+	}
+
+	return false, nil
+}
+
+// issueMFAPendingToken returns the short-lived token that is exchanged together with a code for a session
+func issueMFAPendingToken(userID uint) (string, error) {
+	token, _, err := tokens.GenerateTypedToken(tokens.TypeMFAPending, userID, config.GetDuration("MFA_TOKEN_TTL", 5*time.Minute), nil)
+	return token, err
+}
+
+// SetupTwoFactor creates a new TOTP secret for the logged in user, it is enabled by ConfirmTwoFactor
+func SetupTwoFactor(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, authUser.ID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if user.TOTPEnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if err := initializers.DB.Model(&user).Update("totp_secret", secret).Error; err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(config.GetString("APP_NAME", "Backend Gin"), user.Email, secret),
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves the authenticator app works
+func ConfirmTwoFactor(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var codeInput struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&codeInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, authUser.ID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if user.TOTPEnabledAt != nil || user.TOTPSecret == "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Two-factor authentication is already enabled or was not set up",
+		})
+		return
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, codeInput.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Code": "The code is invalid",
+			},
+		})
+		return
+	}
+
+	// Enable it and create the recovery codes
+	var codes []string
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled_at": time.Now(),
+			"totp_last_step":  step,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication has been enabled, keep the recovery codes in a safe place",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns two-factor authentication off, it needs the password and a code
+func DisableTwoFactor(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var disableInput struct {
+		Password     string `json:"password" binding:"required"`
+		Code         string `json:"code" binding:"required_without=RecoveryCode"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	if err := c.ShouldBindJSON(&disableInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, authUser.ID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if user.TOTPEnabledAt == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Two-factor authentication is not enabled",
+		})
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(disableInput.Password)) != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Password": "The password is incorrect",
+			},
+		})
+		return
+	}
+
+	ok, err := verifySecondFactor(user, disableInput.Code, disableInput.RecoveryCode)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Code": "The code is invalid",
+			},
+		})
+		return
+	}
+
+	// Remove the secret and the recovery codes
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication has been disabled",
+	})
+}
+
+// LoginTwoFactor finishes a login of a user with two-factor authentication
+func LoginTwoFactor(c *gin.Context) {
+	var mfaInput struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code" binding:"required_without=RecoveryCode"`
+		RecoveryCode string `json:"recovery_code"`
+		// TokenInBody returns the tokens in the response instead of cookies, e.g. for mobile apps
+		TokenInBody bool `json:"token_in_body"`
+	}
+
+	if c.ShouldBindJSON(&mfaInput) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read body",
+		})
+		return
+	}
+
+	// The pending token proves the password was checked and can only be used once
+	claims, err := tokens.ParseTypedToken(tokens.TypeMFAPending, mfaInput.MFAToken)
+	if err == nil {
+		var revoked bool
+		if revoked, err = tokens.IsRevoked(claims); err == nil && revoked {
+			err = tokens.ErrInvalidToken
+		}
+	}
+
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or expired login, please log in again",
+		})
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, tokens.UserID(claims)).Error; err != nil || user.TOTPEnabledAt == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or expired login, please log in again",
+		})
+		return
+	}
+
+	// The account may have been disabled since the password was checked
+	if rejectDisabledUser(c, user) {
+		return
+	}
+
+	// Wrong codes count as failed logins of the account
+	retryAfter, err := loginRetryAfter(c, user.Email)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if retryAfter > 0 {
+		tooManyLoginAttempts(c, retryAfter)
+		return
+	}
+
+	ok, err := verifySecondFactor(user, mfaInput.Code, mfaInput.RecoveryCode)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if !ok {
+		failLogin(c, user.Email, "Invalid two-factor code")
+		return
+	}
+
+	succeedLogin(user)
+
+	if err := tokens.RevokeToken(claims); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// Issue the access and refresh tokens
+	response, err := issueSession(c, user.ID, mfaInput.TokenInBody)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to create token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -1,29 +1,37 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
-	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/audit"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/filters"
 	format_errors "github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/format-errors"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // Signup function is used to create a user or signup a user
 func Signup(c *gin.Context) {
-	// Get the name, email and password from request
+	// Get the name, email and password from request, invited users can leave out the email
 	var userInput struct {
-		Name     string `json:"name" binding:"required,min=2,max=50"`
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required,min=6"`
+		Name            string `json:"name" binding:"required,min=2,max=50"`
+		Email           string `json:"email" binding:"required_without=InvitationToken,omitempty,email"`
+		Password        string `json:"password" binding:"required"`
+		InvitationToken string `json:"invitation_token"`
 	}
 
 	if err := c.ShouldBindJSON(&userInput); err != nil {
@@ -40,8 +48,63 @@ func Signup(c *gin.Context) {
 		return
 	}
 
+	// The password must follow the password policy
+	if errs := validations.ValidatePassword(userInput.Password); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Password": errs,
+			},
+		})
+		return
+	}
+
+	// SIGNUP_MODE decides who may sign up
+	switch mode := signupMode(); {
+	case mode == signupModeDisabled:
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Signup is disabled",
+		})
+		return
+	case mode == signupModeInvite && userInput.InvitationToken == "":
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Signup is by invitation only",
+		})
+		return
+	}
+
+	// The invitation fixes the email and the role
+	email, roleName := userInput.Email, rbac.DefaultSignupRole()
+	var invitation models.Invitation
+
+	if userInput.InvitationToken != "" {
+		var err error
+		invitation, err = findInvitation(userInput.InvitationToken)
+		if errors.Is(err, errInvalidInvitation) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid or expired invitation",
+			})
+			return
+		}
+
+		if err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+
+		if email != "" && !strings.EqualFold(email, invitation.Email) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": map[string]interface{}{
+					"Email": "The email does not match the invitation",
+				},
+			})
+			return
+		}
+
+		email, roleName = invitation.Email, invitation.Role
+	}
+
 	// Email unique validation
-	if validations.IsUniqueValue("users", "email", userInput.Email, 0) {
+	if validations.IsUniqueValue("users", "email", email, 0) {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"validations": map[string]interface{}{
 				"Email": "The email is already exist!",
@@ -51,7 +114,7 @@ func Signup(c *gin.Context) {
 	}
 
 	// Hash the password
-	hashPassword, err := bcrypt.GenerateFromPassword([]byte(userInput.Password), 10)
+	hashPassword, err := helpers.HashPassword(userInput.Password)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -61,20 +124,57 @@ func Signup(c *gin.Context) {
 		return
 	}
 
+	// Give the user the default or the invited role
+	roles, err := rbac.FindRoles(initializers.DB, []string{roleName})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
 	user := models.User{
 		Name:     userInput.Name,
-		Email:    userInput.Email,
-		Password: string(hashPassword),
+		Email:    email,
+		Password: hashPassword,
+		Roles:    roles,
 	}
 
-	// Create the user
-	result := initializers.DB.Create(&user)
+	// Create the user, the invitation was sent to the email so it proves the address
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if invitation.ID != 0 {
+			if err := acceptInvitation(tx, invitation.ID); err != nil {
+				return err
+			}
+
+			verifiedAt := time.Now()
+			user.EmailVerifiedAt = &verifiedAt
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionCreate, audit.ResourceUser, user.ID, nil, user)
+	})
+
+	if errors.Is(err, errInvalidInvitation) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid or expired invitation",
+		})
+		return
+	}
 
-	if result.Error != nil {
+	if err != nil {
 		format_errors.InternalServerError(c)
 		return
 	}
 
+	// Send the verification link, the user can ask for a new one if this fails
+	if user.EmailVerifiedAt == nil {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Println("Failed to send the verification email:", err)
+		}
+	}
+
 	// Return the user
 	//user.Password = ""
 
@@ -89,6 +189,8 @@ func Login(c *gin.Context) {
 	var userInput struct {
 		Email    string `json:"email" binding:"required,email"`
 		Password string `json:"password" binding:"required"`
+		// TokenInBody returns the tokens in the response instead of cookies, e.g. for mobile apps
+		TokenInBody bool `json:"token_in_body"`
 	}
 
 	if c.ShouldBindJSON(&userInput) != nil {
@@ -99,37 +201,69 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Stop here while the account or the client IP is locked out
+	retryAfter, err := loginRetryAfter(c, userInput.Email)
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if retryAfter > 0 {
+		tooManyLoginAttempts(c, retryAfter)
+		return
+	}
+
 	// Find the user by email
 	var user models.User
 	initializers.DB.First(&user, "email = ?", userInput.Email)
 
 	if user.ID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid email or password",
-		})
-
+		failLogin(c, userInput.Email, "Invalid email or password")
 		return
 	}
 
 	// Compare the password with user hashed password
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(userInput.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(userInput.Password))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid email or password",
-		})
+		failLogin(c, userInput.Email, "Invalid email or password")
+		return
+	}
 
+	// Disabled accounts cannot log in
+	if rejectDisabledUser(c, user) {
 		return
 	}
 
-	// Generate a JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 24 * 30).Unix(),
-	})
+	// The email address must be verified first
+	if emailVerificationRequired(user) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Please verify your email address before logging in",
+		})
+		return
+	}
+
+	// Users with two-factor authentication exchange this token and a code at /api/login/2fa,
+	// the failed logins are only forgotten once that succeeds
+	if user.TOTPEnabledAt != nil {
+		mfaToken, err := issueMFAPendingToken(user.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to create token",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		})
+		return
+	}
 
-	// Sign in and get the complete encoded token as a string using the .env secret
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET")))
+	succeedLogin(user)
 
+	// Issue the access and refresh tokens
+	response, err := issueSession(c, user.ID, userInput.TokenInBody)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Failed to create token",
@@ -137,36 +271,90 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Set expiry time and send the token back
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("Authorization", tokenString, 3600*24*30, "", "", false, true)
-	c.JSON(http.StatusOK, gin.H{})
+	c.JSON(http.StatusOK, response)
 }
 
 // Logout function is used to log out a user
 func Logout(c *gin.Context) {
-	// Clear the cookie
-	c.SetCookie("Authorization", "", 0, "", "", false, true)
+	// Revoke the access token so a copy of it cannot be used anymore, and end its session
+	if value, ok := c.Get("accessClaims"); ok {
+		claims := value.(jwt.MapClaims)
+		if err := tokens.RevokeToken(claims); err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+
+		if sessionID := tokens.SessionID(claims); sessionID != 0 {
+			err := tokens.RevokeSession(initializers.DB, tokens.UserID(claims), sessionID)
+			if err != nil && !errors.Is(err, tokens.ErrSessionNotFound) {
+				format_errors.InternalServerError(c)
+				return
+			}
+		}
+	}
+
+	// Revoke the refresh token so it cannot be used to get a new access token
+	if refreshToken, _ := refreshTokenFromRequest(c); refreshToken != "" {
+		if err := tokens.RevokeRefreshToken(initializers.DB, refreshToken); err != nil {
+			format_errors.InternalServerError(c)
+			return
+		}
+	}
+
+	// Clear the cookies
+	clearAuthCookies(c)
 
 	c.JSON(http.StatusOK, gin.H{
 		"successMessage": "Logout successful",
 	})
 }
 
+// LogoutAll function is used to log out the user from every session
+func LogoutAll(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	// Revoke every access and refresh token of the user
+	if err := tokens.RevokeAllSessions(initializers.DB, authUser.ID); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	// Clear the cookies
+	clearAuthCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"successMessage": "Logged out from all sessions",
+	})
+}
+
 // ListUsers function is used to get users list
 func ListUsers(c *gin.Context) {
 	// Get all the users
 	var users []models.User
 
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
-
-	perPageStr := c.DefaultQuery("perPage", "5")
-	perPage, _ := strconv.Atoi(perPageStr)
+	page, ok := pageParams(c, "perPage", 5)
+	if !ok {
+		return
+	}
 
-	result, err := pagination.Paginate(initializers.DB, page, perPage, nil, &users)
+	// The pagination parameters are read with the filters, any other parameter is rejected
+	var filter struct {
+		models.UserFilter
+		pagination.CursorParams
+	}
+	scope, err := filters.Parse(&filter, c.Request.URL.Query())
 	if err != nil {
-		format_errors.InternalServerError(c)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	result, ok := paginateList(c, page, filter.CursorParams, scope, &users)
+	if !ok {
 		return
 	}
 
@@ -191,6 +379,7 @@ func GetUser(c *gin.Context) {
 	}
 
 	// Return the user
+	helpers.SetETag(c, user.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"result": user,
 	})
@@ -230,8 +419,23 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// Only the user or an admin can update the user
+	if !helpers.AuthorizeOwnerOrPermission(c, user.ID, rbac.UsersUpdate) {
+		return
+	}
+
+	updateUserProfile(c, user, userInput.Name, userInput.Email)
+}
+
+// updateUserProfile saves the name and email of the user and returns the user
+func updateUserProfile(c *gin.Context, user models.User, name, email string) {
+	// The client must have seen the latest version
+	if !helpers.CheckIfMatch(c, user.Version) {
+		return
+	}
+
 	// Email unique validation
-	if user.Email != userInput.Email && validations.IsUniqueValue("users", "email", userInput.Email, int(user.ID)) {
+	if user.Email != email && validations.IsUniqueValue("users", "email", email, int(user.ID)) {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"validations": map[string]interface{}{
 				"Email": "The email is already exist!",
@@ -240,21 +444,49 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	// Prepare data to update
-	updateUser := models.User{
-		Name:  userInput.Name,
-		Email: userInput.Email,
+	// Prepare data to update, a new email address has to be verified again
+	emailChanged := user.Email != email
+	updateUser := map[string]interface{}{
+		"name":    name,
+		"email":   email,
+		"version": user.Version + 1,
+	}
+	if emailChanged {
+		updateUser["email_verified_at"] = nil
 	}
 
-	// Update the user
-	result = initializers.DB.Model(&user).Updates(&updateUser)
+	// Update the user unless it was changed since it was read
+	before := user
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&user).Where("version = ?", before.Version).Updates(updateUser)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errRecordChanged
+		}
+
+		return recordAudit(tx, c, audit.ActionUpdate, audit.ResourceUser, user.ID, before, user)
+	})
+	if errors.Is(err, errRecordChanged) {
+		helpers.PreconditionFailed(c)
+		return
+	}
 
-	if result.Error != nil {
+	if err != nil {
 		format_errors.InternalServerError(c)
 		return
 	}
 
+	if emailChanged {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Println("Failed to send the verification email:", err)
+		}
+	}
+
 	// Return the user
+	helpers.SetETag(c, user.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"user": user,
 	})
@@ -272,8 +504,23 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
+	// Only the user or an admin can delete the user
+	if !helpers.AuthorizeOwnerOrPermission(c, user.ID, rbac.UsersDelete) {
+		return
+	}
+
 	// Delete the user
-	initializers.DB.Delete(&user)
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionDelete, audit.ResourceUser, user.ID, user, nil)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
 
 	// Return response
 	c.JSON(http.StatusOK, gin.H{
@@ -281,6 +528,136 @@ func DeleteUser(c *gin.Context) {
 	})
 }
 
+// GetProfile function is used to get the logged in user
+func GetProfile(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	// Find the user with the roles
+	var user models.User
+	if err := initializers.DB.Preload("Roles").First(&user, authUser.ID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	helpers.SetETag(c, user.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"user":        user,
+		"permissions": authUser.Permissions,
+	})
+}
+
+// UpdateProfile function is used to update the name and/or email of the logged in user
+func UpdateProfile(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	// Only the given fields are changed
+	var userInput struct {
+		Name  *string `json:"name" binding:"omitempty,min=2,max=50"`
+		Email *string `json:"email" binding:"omitempty,email"`
+	}
+
+	if err := c.ShouldBindJSON(&userInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, authUser.ID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	name, email := user.Name, user.Email
+	if userInput.Name != nil {
+		name = *userInput.Name
+	}
+	if userInput.Email != nil {
+		email = *userInput.Email
+	}
+
+	updateUserProfile(c, user, name, email)
+}
+
+// DeleteProfile function is used to delete the account of the logged in user, it needs the password
+func DeleteProfile(c *gin.Context) {
+	authUser := helpers.GetAuthUser(c)
+	if authUser == nil {
+		return
+	}
+
+	var passwordInput struct {
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&passwordInput); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"validations": validations.FormatValidationErrors(errs),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, authUser.ID).Error; err != nil {
+		format_errors.RecordNotFound(c, err)
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passwordInput.Password)) != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"validations": map[string]interface{}{
+				"Password": "The password is incorrect",
+			},
+		})
+		return
+	}
+
+	// Delete the user and log out everywhere
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionDelete, audit.ResourceUser, user.ID, user, nil)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	if err := tokens.RevokeAllSessions(initializers.DB, user.ID); err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
+
+	clearAuthCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Your account has been deleted",
+	})
+}
+
 // PermanentlyDeleteUser function is used to delete a user permanently
 func PermanentlyDeleteUser(c *gin.Context) {
 	// Get the id from url
@@ -293,8 +670,22 @@ func PermanentlyDeleteUser(c *gin.Context) {
 		return
 	}
 
-	// Delete the user
-	initializers.DB.Unscoped().Delete(&user)
+	// Delete the user and the identities linked to it
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Identity{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(&user).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, c, audit.ActionForceDelete, audit.ResourceUser, user.ID, user, nil)
+	})
+	if err != nil {
+		format_errors.InternalServerError(c)
+		return
+	}
 
 	// Return response
 	c.JSON(http.StatusOK, gin.H{
@@ -1,77 +1,140 @@
 package middleware
 
 import (
-	"fmt"
+	"log"
 	"net/http"
-	"os"
-	"time"
+	"strings"
 
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthUser struct {
-	ID    uint   `json:"ID"`
-	Name  string `json:"Name"`
-	Email string `json:"Email"`
+	ID          uint     `json:"ID"`
+	Name        string   `json:"Name"`
+	Email       string   `json:"Email"`
+	Roles       []string `json:"Roles"`
+	Permissions []string `json:"Permissions"`
+	// APIKeyID is the key the request was authenticated with, 0 for user sessions
+	APIKeyID uint `json:"APIKeyID,omitempty"`
+}
+
+// HasPermission reports whether one of the user's roles grants the permission
+func (u AuthUser) HasPermission(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewAuthUser builds the AuthUser from a user whose roles and permissions are preloaded
+func NewAuthUser(user models.User) AuthUser {
+	roles, permissions := rbac.RoleAndPermissionNames(user)
+
+	return AuthUser{
+		ID:          user.ID,
+		Name:        user.Name,
+		Email:       user.Email,
+		Roles:       roles,
+		Permissions: permissions,
+	}
+}
+
+// tokenFromRequest returns the first access token found in the configured sources.
+// AUTH_TOKEN_SOURCES lists "header" (Authorization: Bearer) and "cookie" in order of precedence.
+func tokenFromRequest(c *gin.Context) (string, bool) {
+	for _, source := range config.GetList("AUTH_TOKEN_SOURCES", []string{"header", "cookie"}) {
+		switch source {
+		case "header":
+			scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+			if found && strings.EqualFold(scheme, "Bearer") && strings.TrimSpace(token) != "" {
+				return strings.TrimSpace(token), true
+			}
+		case "cookie":
+			if token, err := c.Cookie("Authorization"); err == nil && token != "" {
+				return token, true
+			}
+		}
+	}
+
+	return "", false
 }
 
 func RequireAuth(c *gin.Context) {
-	// Get the cookie from the request
-	tokenString, err := c.Cookie("Authorization")
+	// Machine clients send an API key instead of a token
+	if apiKey := c.GetHeader(apiKeyHeader); apiKey != "" {
+		requireAPIKey(c, apiKey)
+		return
+	}
+
+	// Get the token from the header or the cookie
+	tokenString, ok := tokenFromRequest(c)
+
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
 
+	// Decode and validate it, expired tokens and tokens of another type are rejected
+	claims, err := tokens.ParseAccessToken(tokenString)
 	if err != nil {
-		c.AbortWithStatus(http.StatusUnauthorized)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
 	}
 
-	// Decode and validate it
-	// Parse and takes the token string and a function for look
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate the alg
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
+	// Reject tokens revoked by a logout
+	if revoked, err := tokens.IsRevoked(claims); err != nil || revoked {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
 
-		return []byte(os.Getenv("SECRET")), nil
-	})
+	// Find the user with token sub together with the roles
+	var user models.User
+	initializers.DB.Preload("Roles.Permissions").Find(&user, tokens.UserID(claims))
 
-	if err != nil || !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		c.Abort()
+	if user.ID == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Unauthorized",
+		})
 		return
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		// Check the expiration time
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			c.AbortWithStatus(http.StatusUnauthorized)
-		}
+	// Tokens of disabled users stop working right away
+	if user.Status == models.UserStatusDisabled {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "Your account has been disabled",
+		})
+		return
+	}
 
-		// Find the user with token sub
-		var user models.User
-		initializers.DB.Find(&user, claims["sub"])
+	// Reject tokens of a revoked session and remember when the device was last seen
+	if sessionID := tokens.SessionID(claims); sessionID != 0 {
+		var session models.Session
+		initializers.DB.Where("user_id = ?", user.ID).Find(&session, sessionID)
 
-		if user.ID == 0 {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Unauthorized",
-			})
+		if session.ID == 0 || session.RevokedAt != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 
-		authUser := AuthUser{
-			ID:    user.ID,
-			Name:  user.Name,
-			Email: user.Email,
+		if err := tokens.TouchSession(initializers.DB, session, c.ClientIP()); err != nil {
+			log.Println("Failed to update the session:", err)
 		}
+	}
 
-		// Attach the user to request
-		c.Set("authUser", authUser)
+	authUser := NewAuthUser(user)
 
-		// Continue
-		c.Next()
-	} else {
-		c.AbortWithStatus(http.StatusUnauthorized)
-	}
+	// Attach the user and the token claims to request
+	c.Set("authUser", authUser)
+	c.Set("accessClaims", claims)
+
+	// Continue
+	c.Next()
 }
@@ -3,54 +3,118 @@ package router
 import (
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/api/controllers"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/api/middleware"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
 	"github.com/gin-gonic/gin"
 )
 
 func GetRoute(r *gin.Engine) {
+	// Public keys for verifying our tokens
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
+
 	// User routes
 	r.POST("/api/signup", controllers.Signup)
 	r.POST("/api/login", controllers.Login)
+	r.POST("/api/login/2fa", controllers.LoginTwoFactor)
+	r.POST("/api/token/refresh", controllers.RefreshToken)
+	r.GET("/api/verify-email", controllers.VerifyEmail)
+	r.POST("/api/verify-email/resend", controllers.ResendVerificationEmail)
+	r.POST("/api/password/forgot", controllers.ForgotPassword)
+	r.POST("/api/password/reset", controllers.ResetPassword)
+	r.GET("/api/oidc/:provider/login", controllers.OIDCLogin)
+	r.GET("/api/oidc/:provider/callback", controllers.OIDCCallback)
 
 	r.Use(middleware.RequireAuth)
 	r.POST("/api/logout", controllers.Logout)
+	r.POST("/api/logout-all", middleware.RequireUserSession, controllers.LogoutAll)
 	userRouter := r.Group("/api/users")
 	{
-		userRouter.GET("/", controllers.ListUsers)
-		userRouter.GET("/:id", controllers.GetUser)
+		userRouter.GET("/me", controllers.GetProfile)
+		userRouter.PATCH("/me", middleware.RequireUserSession, controllers.UpdateProfile)
+		userRouter.DELETE("/me", middleware.RequireUserSession, controllers.DeleteProfile)
+		userRouter.PUT("/me/password", middleware.RequireUserSession, controllers.ChangePassword)
+		userRouter.POST("/me/2fa/setup", middleware.RequireUserSession, controllers.SetupTwoFactor)
+		userRouter.POST("/me/2fa/confirm", middleware.RequireUserSession, controllers.ConfirmTwoFactor)
+		userRouter.POST("/me/2fa/disable", middleware.RequireUserSession, controllers.DisableTwoFactor)
+		userRouter.GET("/me/sessions", middleware.RequireUserSession, controllers.ListSessions)
+		userRouter.DELETE("/me/sessions/:id", middleware.RequireUserSession, controllers.RevokeSession)
+		userRouter.GET("/me/api-keys", middleware.RequireUserSession, controllers.ListAPIKeys)
+		userRouter.POST("/me/api-keys", middleware.RequireUserSession, controllers.CreateAPIKey)
+		userRouter.DELETE("/me/api-keys/:id", middleware.RequireUserSession, controllers.RevokeAPIKey)
+		userRouter.GET("/", middleware.RequirePermission(rbac.UsersRead), controllers.ListUsers)
+		userRouter.POST("/create", middleware.RequirePermission(rbac.UsersCreate), controllers.CreateUser)
+		userRouter.GET("/trashed", middleware.RequirePermission(rbac.UsersRestore), controllers.ListTrashedUsers)
+		userRouter.POST("/:id/restore", middleware.RequirePermission(rbac.UsersRestore), controllers.RestoreUser)
+		userRouter.GET("/:id", middleware.RequirePermission(rbac.UsersRead), controllers.GetUser)
 		userRouter.PUT("/update/:id", controllers.UpdateUser)
+		userRouter.PUT("/:id/roles", middleware.RequirePermission(rbac.RolesAssign), controllers.AssignUserRoles)
+		userRouter.POST("/:id/unlock", middleware.RequirePermission(rbac.UsersUnlock), controllers.UnlockUser)
+		userRouter.POST("/:id/disable", middleware.RequirePermission(rbac.UsersDisable), controllers.DisableUser)
+		userRouter.POST("/:id/enable", middleware.RequirePermission(rbac.UsersDisable), controllers.EnableUser)
+		userRouter.POST("/:id/logout", middleware.RequirePermission(rbac.UsersLogout), controllers.LogoutUser)
 		userRouter.DELETE("/:id", controllers.DeleteUser)
-		userRouter.DELETE("/delete-permanent/:id", controllers.PermanentlyDeleteUser)
+		userRouter.DELETE("/delete-permanent/:id", middleware.RequirePermission(rbac.UsersForceDelete), controllers.PermanentlyDeleteUser)
+	}
+
+	// Invitation routes
+	invitationRouter := r.Group("/api/invitations")
+	{
+		invitationRouter.GET("/", middleware.RequirePermission(rbac.InvitationsRead), controllers.ListInvitations)
+		invitationRouter.POST("/create", middleware.RequirePermission(rbac.InvitationsCreate), controllers.CreateInvitation)
+		invitationRouter.DELETE("/:id", middleware.RequirePermission(rbac.InvitationsRevoke), controllers.RevokeInvitation)
+	}
+
+	// Role routes
+	roleRouter := r.Group("/api/roles")
+	{
+		roleRouter.GET("/", middleware.RequirePermission(rbac.RolesRead), controllers.ListRoles)
+		roleRouter.GET("/permissions", middleware.RequirePermission(rbac.RolesRead), controllers.ListPermissions)
+		roleRouter.POST("/create", middleware.RequirePermission(rbac.RolesManage), controllers.CreateRole)
 	}
 
 	// Book routes
 	bookRouter := r.Group("/api/books")
 	{
-		bookRouter.GET("/", controllers.ListBook)
-		bookRouter.POST("/create", controllers.CreateBook)
-		bookRouter.GET("/:id", controllers.GetBook)
-		bookRouter.PUT("/update/:id", controllers.UpdateBook)
-		bookRouter.DELETE("/:id", controllers.DeleteBook)
-		bookRouter.DELETE("/delete-permanent/:id", controllers.DeleteBookPermanent)
+		bookRouter.GET("/", middleware.RequirePermission(rbac.BooksRead), controllers.ListBook)
+		bookRouter.GET("/trashed", middleware.RequirePermission(rbac.BooksRestore), controllers.ListTrashedBooks)
+		bookRouter.POST("/create", middleware.RequirePermission(rbac.BooksCreate), controllers.CreateBook)
+		bookRouter.GET("/:id", middleware.RequirePermission(rbac.BooksRead), controllers.GetBook)
+		bookRouter.POST("/:id/restore", middleware.RequirePermission(rbac.BooksRestore), controllers.RestoreBook)
+		bookRouter.PUT("/update/:id", middleware.RequirePermission(rbac.BooksUpdate), controllers.UpdateBook)
+		bookRouter.GET("/:id/stock", middleware.RequirePermission(rbac.BooksRead), controllers.ListStockMovements)
+		bookRouter.POST("/:id/stock", middleware.RequirePermission(rbac.BooksStock), controllers.CreateStockMovement)
+		bookRouter.DELETE("/:id", middleware.RequirePermission(rbac.BooksDelete), controllers.DeleteBook)
+		bookRouter.DELETE("/delete-permanent/:id", middleware.RequirePermission(rbac.BooksForceDelete), controllers.DeleteBookPermanent)
 	}
 
 	// Employee routes
 	customerRouter := r.Group("/api/customers")
 	{
-		customerRouter.GET("/", controllers.ListEmployee)
-		customerRouter.POST("/create", controllers.CreateEmployee)
-		customerRouter.GET("/:id", controllers.GetEmployee)
-		customerRouter.PUT("/update/:id", controllers.UpdateEmployee)
-		customerRouter.DELETE("/:id", controllers.DeleteEmployee)
-		customerRouter.DELETE("/delete-permanent/:id", controllers.DeleteEmployeePermanent)
+		customerRouter.GET("/", middleware.RequirePermission(rbac.EmployeesRead), controllers.ListEmployee)
+		customerRouter.POST("/create", middleware.RequirePermission(rbac.EmployeesCreate), controllers.CreateEmployee)
+		customerRouter.GET("/trashed", middleware.RequirePermission(rbac.EmployeesRestore), controllers.ListTrashedEmployees)
+		customerRouter.GET("/:id", middleware.RequirePermission(rbac.EmployeesRead), controllers.GetEmployee)
+		customerRouter.POST("/:id/restore", middleware.RequirePermission(rbac.EmployeesRestore), controllers.RestoreEmployee)
+		customerRouter.PUT("/update/:id", middleware.RequirePermission(rbac.EmployeesUpdate), controllers.UpdateEmployee)
+		customerRouter.DELETE("/:id", middleware.RequirePermission(rbac.EmployeesDelete), controllers.DeleteEmployee)
+		customerRouter.DELETE("/delete-permanent/:id", middleware.RequirePermission(rbac.EmployeesForceDelete), controllers.DeleteEmployeePermanent)
 	}
 
 	// Order routes
 	orderRouter := r.Group("/api/orders")
 	{
-		orderRouter.GET("/", controllers.ListOrders)
-		orderRouter.POST("/create", controllers.CreateOrder)
-		orderRouter.PUT("/update/:id", controllers.UpdateOrder)
-		orderRouter.DELETE("/:id", controllers.DeleteOrder)
-		orderRouter.DELETE("/delete-permanent/:id", controllers.PermanentlyDeleteOrder)
+		orderRouter.GET("/", middleware.RequirePermission(rbac.OrdersRead), controllers.ListOrders)
+		orderRouter.GET("/trashed", middleware.RequirePermission(rbac.OrdersRestore), controllers.ListTrashedOrders)
+		orderRouter.POST("/create", middleware.RequirePermission(rbac.OrdersCreate), controllers.CreateOrder)
+		orderRouter.GET("/:id", middleware.RequirePermission(rbac.OrdersRead), controllers.GetOrder)
+		orderRouter.POST("/:id/restore", middleware.RequirePermission(rbac.OrdersRestore), controllers.RestoreOrder)
+		orderRouter.PUT("/update/:id", middleware.RequirePermission(rbac.OrdersUpdate), controllers.UpdateOrder)
+		orderRouter.DELETE("/:id", middleware.RequirePermission(rbac.OrdersDelete), controllers.DeleteOrder)
+		orderRouter.DELETE("/delete-permanent/:id", middleware.RequirePermission(rbac.OrdersForceDelete), controllers.PermanentlyDeleteOrder)
+	}
+
+	// Audit log routes
+	auditRouter := r.Group("/api/audit-logs")
+	{
+		auditRouter.GET("/", middleware.RequirePermission(rbac.AuditRead), controllers.ListAuditLogs)
 	}
 }
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetString returns the env value for key or the fallback when it is empty
+func GetString(key, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// GetInt returns the env value for key as an int or the fallback when it is empty or invalid
+func GetInt(key string, fallback int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+// GetBool returns the env value for key as a bool or the fallback when it is empty or invalid
+func GetBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+// GetDuration returns the env value for key as a duration (e.g. "15m") or the fallback when it is empty or invalid
+func GetDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(strings.TrimSpace(os.Getenv(key)))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+
+	return value
+}
+
+// GetList returns the comma separated env value for key as a trimmed slice or the fallback when it is empty
+func GetList(key string, fallback []string) []string {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
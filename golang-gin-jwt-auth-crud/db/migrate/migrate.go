@@ -1,11 +1,18 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
 )
 
 func init() {
@@ -13,15 +20,142 @@ func init() {
 	initializers.ConnectDB()
 }
 
+// fresh drops every table before migrating, use -fresh=false to keep the existing data
+var fresh = flag.Bool("fresh", true, "drop all tables before migrating")
+
 func main() {
-	err := initializers.DB.Migrator().DropTable(models.User{}, models.Order{}, models.Book{}, models.Employee{})
+	flag.Parse()
+
+	if *fresh {
+		dropTables()
+	} else {
+		dropUniqueConstraints()
+	}
+
+	// Users from before email verification are backfilled once, when the column is added
+	backfillVerifiedAt := !initializers.DB.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
+	// The join tables are created from the many2many tags of the models
+	err := initializers.DB.AutoMigrate(
+		models.User{}, models.Order{}, models.Book{}, models.Employee{},
+		models.RefreshToken{}, models.RevokedToken{}, models.UserTokenCutoff{},
+		models.Role{}, models.Permission{}, models.PasswordReset{}, models.RecoveryCode{},
+		models.LoginAttempt{}, models.Invitation{}, models.Identity{}, models.APIKey{},
+		models.Session{}, models.AuditLog{}, models.StockMovement{},
+	)
+
+	if err != nil {
+		log.Fatal("Migration failed")
+	}
+
+	// Books had no timestamps before they became soft deletable
+	err = initializers.DB.Exec("UPDATE books SET created_at = now(), updated_at = now() WHERE created_at IS NULL").Error
+	if err != nil {
+		log.Fatal("Backfilling the book timestamps failed")
+	}
+
+	// Existing users keep logging in, the address they have been using counts as verified
+	if backfillVerifiedAt {
+		err = initializers.DB.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error
+		if err != nil {
+			log.Fatal("Backfilling the email verification failed")
+		}
+	}
+
+	// The stock of books from before the ledger becomes their opening balance
+	err = initializers.DB.Exec(`INSERT INTO stock_movements (book_id, type, quantity, balance_after, reason, created_at)
+		SELECT id, ?, qty, qty, ?, now() FROM books
+		WHERE qty <> 0 AND NOT EXISTS (SELECT 1 FROM stock_movements WHERE stock_movements.book_id = books.id)`, models.StockAdjustment, models.StockOpeningReason).Error
+	if err != nil {
+		log.Fatal("Backfilling the stock movements failed")
+	}
+
+	// Create the permissions and default roles
+	if err = rbac.Seed(initializers.DB); err != nil {
+		log.Fatal("Seeding roles failed")
+	}
+
+	// Users without a role, such as those from before the roles, get the default one, otherwise every route answers 403
+	if !*fresh {
+		err = initializers.DB.Exec(`INSERT INTO user_roles (user_id, role_id)
+			SELECT users.id, roles.id FROM users, roles
+			WHERE roles.name = ? AND roles.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id)`, rbac.DefaultSignupRole()).Error
+		if err != nil {
+			log.Fatal("Assigning the default role failed")
+		}
+	}
+
+	seedAdmin()
+}
+
+// dropTables drops every table of the application
+func dropTables() {
+	err := initializers.DB.Migrator().DropTable(
+		"user_roles", "role_permissions", "order_books",
+		models.User{}, models.Order{}, models.Book{}, models.Employee{},
+		models.RefreshToken{}, models.RevokedToken{}, models.UserTokenCutoff{},
+		models.Role{}, models.Permission{}, models.PasswordReset{}, models.RecoveryCode{},
+		models.LoginAttempt{}, models.Invitation{}, models.Identity{}, models.APIKey{},
+		models.Session{}, models.AuditLog{}, models.StockMovement{},
+	)
 	if err != nil {
 		log.Fatal("Table dropping failed")
 	}
+}
 
-	err = initializers.DB.AutoMigrate(models.User{}, models.Order{}, models.Book{}, models.Employee{})
+// dropUniqueConstraints drops the unique constraints that also covered the soft deleted records,
+// the partial unique indexes of the models replace them
+func dropUniqueConstraints() {
+	err := initializers.DB.Exec("ALTER TABLE IF EXISTS users DROP CONSTRAINT IF EXISTS users_email_key").Error
+	if err == nil {
+		err = initializers.DB.Exec("ALTER TABLE IF EXISTS employees DROP CONSTRAINT IF EXISTS employees_name_key, DROP CONSTRAINT IF EXISTS employees_email_key").Error
+	}
 
 	if err != nil {
-		log.Fatal("Migration failed")
+		log.Fatal("Dropping the unique constraints failed")
+	}
+}
+
+// seedAdmin creates the first admin from ADMIN_EMAIL and ADMIN_PASSWORD, nobody else can assign roles yet
+func seedAdmin() {
+	email, password := os.Getenv("ADMIN_EMAIL"), os.Getenv("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		log.Println("ADMIN_EMAIL or ADMIN_PASSWORD is not set, no admin user was created")
+		return
+	}
+
+	// The admin is kept when migrating without -fresh
+	var count int64
+	initializers.DB.Model(&models.User{}).Unscoped().Where("email = ?", email).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	if errs := validations.ValidatePassword(password); len(errs) > 0 {
+		log.Fatalf("ADMIN_PASSWORD does not follow the password policy: %s", strings.Join(errs, ", "))
+	}
+
+	hashPassword, err := helpers.HashPassword(password)
+	if err != nil {
+		log.Fatal("Failed to hash the admin password")
+	}
+
+	roles, err := rbac.FindRoles(initializers.DB, []string{rbac.RoleAdmin})
+	if err != nil {
+		log.Fatal("Admin role not found")
+	}
+
+	verifiedAt := time.Now()
+	admin := models.User{
+		Name:            config.GetString("ADMIN_NAME", "Admin"),
+		Email:           email,
+		Password:        hashPassword,
+		EmailVerifiedAt: &verifiedAt,
+		Roles:           roles,
+	}
+
+	if err = initializers.DB.Create(&admin).Error; err != nil {
+		log.Fatal("Creating the admin user failed")
 	}
 }
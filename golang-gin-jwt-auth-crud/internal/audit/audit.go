@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"gorm.io/gorm"
+)
+
+// Actions written to the audit log
+const (
+	ActionCreate      = "create"
+	ActionUpdate      = "update"
+	ActionDelete      = "delete"
+	ActionForceDelete = "force_delete"
+	ActionRestore     = "restore"
+)
+
+// Resource types written to the audit log
+const (
+	ResourceBook     = "book"
+	ResourceEmployee = "employee"
+	ResourceOrder    = "order"
+	ResourceUser     = "user"
+)
+
+// ignoredFields change on every write and would only add noise
+var ignoredFields = map[string]bool{
+	"CreatedAt":  true,
+	"UpdatedAt":  true,
+	"created_at": true,
+	"updated_at": true,
+	"version":    true,
+}
+
+// Diff returns the fields whose JSON value differs between before and after. before is nil for a created
+// record and after is nil for a deleted one. Fields hidden from JSON, such as password hashes, are never included.
+func Diff(before, after interface{}) (models.AuditChanges, error) {
+	old, err := toMap(before)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := toMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(models.AuditChanges)
+	for field, value := range old {
+		if !ignoredFields[field] && !reflect.DeepEqual(value, updated[field]) {
+			changes[field] = models.AuditChange{Old: value, New: updated[field]}
+		}
+	}
+
+	for field, value := range updated {
+		if _, ok := old[field]; !ok && !ignoredFields[field] && value != nil {
+			changes[field] = models.AuditChange{Old: nil, New: value}
+		}
+	}
+
+	return changes, nil
+}
+
+// Record writes the entry with the difference between before and after
+func Record(db *gorm.DB, entry models.AuditLog, before, after interface{}) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	entry.Changes = changes
+	return db.Create(&entry).Error
+}
+
+// toMap turns a record into its JSON fields
+func toMap(record interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if record == nil {
+		return fields, nil
+	}
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
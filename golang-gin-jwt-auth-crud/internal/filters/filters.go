@@ -0,0 +1,441 @@
+// Package filters turns the query string of a list endpoint into GORM conditions and an order.
+//
+// The filters of a list are declared with the tags of a struct, e.g.
+//
+//	Price    *int   `form:"price" filter:"price,eq,lt,gte,between,sort"`
+//	PriceMin *int   `form:"price_min" filter:"price,gte"`
+//	Search   string `form:"search" filter:"title|category,like"`
+//	Page     int    `form:"page"`
+//
+// The form tag names the query parameter. The filter tag lists the columns, joined with "|" when any of
+// them may match, then the allowed operators and "sort" when the list can be sorted by the column.
+// time.Time fields take dates like 2006-01-02 or RFC 3339 times, string fields of date columns are
+// checked with the "date" option, e.g. filter:"birth_date,date,eq,between".
+// "price=5" uses the first operator, "price[gte]=5" picks another one and "sort=-price,title" sorts.
+// Fields without a filter tag, or with filter:"-", are read into the struct but left to the caller.
+// Any other query parameter is rejected.
+package filters
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Operators that can be allowed in a filter tag
+const (
+	OpEq      = "eq"
+	OpNe      = "ne"
+	OpLt      = "lt"
+	OpLte     = "lte"
+	OpGt      = "gt"
+	OpGte     = "gte"
+	OpIn      = "in"
+	OpLike    = "like"
+	OpBetween = "between"
+)
+
+// SortParam is the query parameter holding the comma separated sort columns, "-" sorts descending
+const SortParam = "sort"
+
+// DateLayout is the format of the dates in the query string
+const DateLayout = "2006-01-02"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// comparisons are the SQL operators of the operators comparing one value
+var comparisons = map[string]string{
+	OpEq:  "=",
+	OpNe:  "<>",
+	OpLt:  "<",
+	OpLte: "<=",
+	OpGt:  ">",
+	OpGte: ">=",
+}
+
+// field is a query parameter declared by a field of the filter struct
+type field struct {
+	index    []int
+	param    string
+	columns  []string
+	ops      []string
+	sortable bool
+	// date requires the values to be dates
+	date bool
+}
+
+// allows reports whether the operator can be used with the field
+func (f field) allows(op string) bool {
+	for _, allowed := range f.ops {
+		if allowed == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// defaultOp is the operator used when the parameter has none, fields without operators are not filtered
+func (f field) defaultOp() string {
+	if len(f.ops) == 0 {
+		return ""
+	}
+
+	return f.ops[0]
+}
+
+// declaration holds the parsed tags of a filter struct
+type declaration struct {
+	fields   map[string]field
+	sortable map[string]bool
+}
+
+var declarations sync.Map
+
+// declare parses the tags of the filter struct type once, invalid tags are programming errors and panic
+func declare(t reflect.Type) declaration {
+	if cached, ok := declarations.Load(t); ok {
+		return cached.(declaration)
+	}
+
+	d := declaration{fields: make(map[string]field), sortable: make(map[string]bool)}
+	declareFields(t, nil, d)
+
+	declarations.Store(t, d)
+	return d
+}
+
+// declareFields adds the fields of the struct type to the declaration, embedded structs share their parameters
+func declareFields(t reflect.Type, index []int, d declaration) {
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		if structField.Anonymous && structField.Type.Kind() == reflect.Struct {
+			declareFields(structField.Type, fieldIndex, d)
+			continue
+		}
+
+		param, _, _ := strings.Cut(structField.Tag.Get("form"), ",")
+		if param == "" || param == "-" {
+			continue
+		}
+
+		f := field{index: fieldIndex, param: param}
+
+		if tag := structField.Tag.Get("filter"); tag != "" && tag != "-" {
+			parts := strings.Split(tag, ",")
+			f.columns = strings.Split(parts[0], "|")
+
+			for _, option := range parts[1:] {
+				switch {
+				case option == "sort":
+					f.sortable = true
+				case option == "date":
+					f.date = true
+				case option == OpIn || option == OpLike || option == OpBetween || comparisons[option] != "":
+					f.ops = append(f.ops, option)
+				default:
+					panic(fmt.Sprintf("filters: unknown option %q in the filter tag of %s.%s", option, t.Name(), structField.Name))
+				}
+			}
+
+			if len(f.ops) == 0 {
+				f.ops = []string{OpEq}
+			}
+
+			if f.date && elemType(structField.Type).Kind() != reflect.String {
+				panic(fmt.Sprintf("filters: %s.%s must be a string to use the date option", t.Name(), structField.Name))
+			}
+
+			if f.sortable {
+				if len(f.columns) != 1 {
+					panic(fmt.Sprintf("filters: %s.%s cannot be sorted by several columns", t.Name(), structField.Name))
+				}
+				d.sortable[f.columns[0]] = true
+			}
+		}
+
+		d.fields[param] = f
+	}
+}
+
+// splitParam splits "price[gte]" into the parameter and the operator
+func splitParam(key string) (string, string) {
+	if open := strings.Index(key, "["); open > 0 && strings.HasSuffix(key, "]") {
+		return key[:open], key[open+1 : len(key)-1]
+	}
+
+	return key, ""
+}
+
+// elemType is the type of the values of a struct field, slices and pointers use their element type
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+// parseTime reads a date, taken as midnight UTC, or an RFC 3339 time
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(DateLayout, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// convert parses a query value into the type of the struct field
+func convert(t reflect.Type, value string) (reflect.Value, error) {
+	t = elemType(t)
+	v := reflect.New(t).Elem()
+
+	if t == timeType {
+		parsed, err := parseTime(value)
+		if err != nil {
+			return v, fmt.Errorf("must be a date like %s or a time like %s", DateLayout, time.RFC3339)
+		}
+		v.Set(reflect.ValueOf(parsed))
+
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("must be a whole number")
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("must be a positive whole number")
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("must be a number")
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return v, fmt.Errorf("must be true or false")
+		}
+		v.SetBool(b)
+	default:
+		return v, fmt.Errorf("cannot be filtered")
+	}
+
+	return v, nil
+}
+
+// assign stores the converted values in the struct field
+func assign(target reflect.Value, values []reflect.Value) {
+	switch target.Kind() {
+	case reflect.Slice:
+		for _, value := range values {
+			target.Set(reflect.Append(target, value))
+		}
+	case reflect.Ptr:
+		pointer := reflect.New(target.Type().Elem())
+		pointer.Elem().Set(values[0])
+		target.Set(pointer)
+	default:
+		target.Set(values[0])
+	}
+}
+
+// LikePattern matches value anywhere in a column, the LIKE wildcards in value only match themselves
+func LikePattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(value) + "%"
+}
+
+// condition builds the SQL condition of one filter, several columns match when any of them does
+func condition(columns []string, op string, values []interface{}) clause.Expression {
+	expressions := make([]clause.Expression, 0, len(columns))
+
+	for _, column := range columns {
+		quoted := clause.Column{Name: column}
+
+		switch op {
+		case OpIn:
+			expressions = append(expressions, clause.IN{Column: quoted, Values: values})
+		case OpLike:
+			expressions = append(expressions, clause.Expr{SQL: "? ILIKE ?", Vars: []interface{}{quoted, LikePattern(values[0].(string))}})
+		case OpBetween:
+			expressions = append(expressions, clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{quoted, values[0], values[1]}})
+		default:
+			expressions = append(expressions, clause.Expr{SQL: "? " + comparisons[op] + " ?", Vars: []interface{}{quoted, values[0]}})
+		}
+	}
+
+	if len(expressions) == 1 {
+		return expressions[0]
+	}
+
+	return clause.Or(expressions...)
+}
+
+// Parse reads the query values into the filter, a pointer to a filter struct. The values given without
+// an operator are stored in the struct fields, the returned scope adds the conditions and the order,
+// by id when no sort is given. The error describes the first invalid parameter for the client.
+func Parse(filter interface{}, values map[string][]string) (func(*gorm.DB) *gorm.DB, error) {
+	target := reflect.ValueOf(filter).Elem()
+	d := declare(target.Type())
+
+	// Sorted keys keep the generated SQL stable
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var conditions []clause.Expression
+	var order []clause.OrderByColumn
+
+	for _, key := range keys {
+		if key == SortParam {
+			var err error
+			if order, err = parseSort(d, values[key]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		param, op := splitParam(key)
+		f, ok := d.fields[param]
+		if !ok {
+			return nil, fmt.Errorf("unknown query parameter %q", key)
+		}
+
+		if op == "" {
+			op = f.defaultOp()
+		} else if !f.allows(op) {
+			return nil, fmt.Errorf("%s cannot be filtered with %q", param, op)
+		}
+
+		// Lists are given as repeated parameters or separated by commas
+		var raw []string
+		for _, value := range values[key] {
+			if op == OpIn || op == OpBetween || (op == "" && target.FieldByIndex(f.index).Kind() == reflect.Slice) {
+				raw = append(raw, strings.Split(value, ",")...)
+			} else {
+				raw = append(raw, value)
+			}
+		}
+
+		var parsed []reflect.Value
+		for _, value := range raw {
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+
+			if f.date {
+				if _, err := time.Parse(DateLayout, value); err != nil {
+					return nil, fmt.Errorf("%s must be a date like %s", param, DateLayout)
+				}
+			}
+
+			v, err := convert(target.FieldByIndex(f.index).Type(), value)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s", param, err.Error())
+			}
+			parsed = append(parsed, v)
+		}
+
+		// An empty parameter is not filtered
+		if len(parsed) == 0 {
+			continue
+		}
+
+		if op == OpBetween && len(parsed) != 2 {
+			return nil, fmt.Errorf("%s[between] needs two values separated by a comma", param)
+		}
+
+		if op != OpIn && op != OpBetween && len(parsed) > 1 {
+			return nil, fmt.Errorf("%s can only be given once", key)
+		}
+
+		if op == OpLike && target.FieldByIndex(f.index).Type().Kind() != reflect.String {
+			return nil, fmt.Errorf("%s cannot be filtered with %q", param, op)
+		}
+
+		if op == f.defaultOp() {
+			assign(target.FieldByIndex(f.index), parsed)
+		}
+
+		if op == "" {
+			continue
+		}
+
+		vars := make([]interface{}, 0, len(parsed))
+		for _, v := range parsed {
+			vars = append(vars, v.Interface())
+		}
+		conditions = append(conditions, condition(f.columns, op, vars))
+	}
+
+	// The id keeps the order stable between pages
+	if !sortsByID(order) {
+		order = append(order, clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	}
+
+	return func(query *gorm.DB) *gorm.DB {
+		for _, c := range conditions {
+			query = query.Where(c)
+		}
+
+		return query.Clauses(clause.OrderBy{Columns: order})
+	}, nil
+}
+
+// parseSort reads "-price,title" into the order, only the columns declared as sortable are allowed
+func parseSort(d declaration, values []string) ([]clause.OrderByColumn, error) {
+	var order []clause.OrderByColumn
+
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			desc := strings.HasPrefix(name, "-")
+			name = strings.TrimLeft(name, "-+")
+
+			if name == "" {
+				continue
+			}
+
+			if !d.sortable[name] && name != "id" {
+				return nil, fmt.Errorf("cannot sort by %q", name)
+			}
+
+			order = append(order, clause.OrderByColumn{Column: clause.Column{Name: name}, Desc: desc})
+		}
+	}
+
+	return order, nil
+}
+
+// sortsByID reports whether the order already contains the id
+func sortsByID(order []clause.OrderByColumn) bool {
+	for _, column := range order {
+		if column.Column.Name == "id" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -24,3 +24,23 @@ func GetAuthUser(c *gin.Context) *middleware.AuthUser {
 
 	return nil
 }
+
+// AuthorizeOwnerOrPermission allows the owner of a record or a user with the permission,
+// otherwise it responds with 403 and returns false. An API key is only allowed by the permission,
+// its scopes must not be bypassed by acting on the account of its owner.
+func AuthorizeOwnerOrPermission(c *gin.Context, ownerID uint, permission string) bool {
+	authUser := GetAuthUser(c)
+	if authUser == nil {
+		return false
+	}
+
+	isOwner := authUser.ID == ownerID && authUser.APIKeyID == 0
+	if isOwner || authUser.HasPermission(permission) {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "You do not have permission to perform this action",
+	})
+	return false
+}
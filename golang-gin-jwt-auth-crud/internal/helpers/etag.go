@@ -0,0 +1,54 @@
+package helpers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/gin-gonic/gin"
+)
+
+// ETag returns the entity tag of a record version
+func ETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// SetETag sets the ETag header to the version of the returned record
+func SetETag(c *gin.Context, version uint) {
+	c.Header("ETag", ETag(version))
+}
+
+// CheckIfMatch compares the If-Match header with the current version of the record. A stale tag is
+// answered with 412, a missing header with 428 when REQUIRE_IF_MATCH is set. It returns false after responding.
+func CheckIfMatch(c *gin.Context, version uint) bool {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" {
+		if config.GetBool("REQUIRE_IF_MATCH", false) {
+			c.JSON(http.StatusPreconditionRequired, gin.H{
+				"error": "The If-Match header with the ETag of the record is required",
+			})
+			return false
+		}
+
+		return true
+	}
+
+	current := ETag(version)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		// Weak tags never match, If-Match uses the strong comparison
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == current {
+			return true
+		}
+	}
+
+	PreconditionFailed(c)
+	return false
+}
+
+// PreconditionFailed responds with 412, the client has to reload the record
+func PreconditionFailed(c *gin.Context) {
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error": "The record has been changed by someone else, reload it and try again",
+	})
+}
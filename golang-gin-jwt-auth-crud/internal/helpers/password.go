@@ -0,0 +1,17 @@
+package helpers
+
+import (
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword hashes the password with bcrypt using the BCRYPT_COST env variable
+func HashPassword(password string) (string, error) {
+	cost := config.GetInt("BCRYPT_COST", 10)
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+
+	hashPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	return string(hashPassword), err
+}
@@ -0,0 +1,115 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Errors of SignedQuantity
+var (
+	ErrUnknownType     = errors.New("unknown stock movement type")
+	ErrInvalidQuantity = errors.New("the quantity must be positive, adjustments must not be zero")
+)
+
+// InsufficientStockError is returned by Move when a movement would take the stock below zero
+type InsufficientStockError struct {
+	BookID    uint
+	Title     string
+	Available int
+	Requested int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("not enough stock of %q, %d available and %d requested", e.Title, e.Available, e.Requested)
+}
+
+// SignedQuantity returns the change of the stock made by a movement of quantity copies. Receipts, sales
+// and returns are given as positive quantities, adjustments carry their own sign.
+func SignedQuantity(movementType string, quantity int) (int, error) {
+	switch movementType {
+	case models.StockReceipt, models.StockReturn:
+		if quantity <= 0 {
+			return 0, ErrInvalidQuantity
+		}
+		return quantity, nil
+	case models.StockSale:
+		if quantity <= 0 {
+			return 0, ErrInvalidQuantity
+		}
+		return -quantity, nil
+	case models.StockAdjustment:
+		if quantity == 0 {
+			return 0, ErrInvalidQuantity
+		}
+		return quantity, nil
+	}
+
+	return 0, ErrUnknownType
+}
+
+// Move applies the signed quantity of the movement to the stock of its book and writes the movement
+// with the new balance. tx must be a transaction, the book row stays locked until it ends so concurrent
+// movements of the same book are applied one after another.
+func Move(tx *gorm.DB, movement *models.StockMovement) error {
+	// Soft deleted books keep their stock, returns of old orders still reach them
+	var book models.Book
+	err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, title, qty").First(&book, movement.BookID).Error
+	if err != nil {
+		return err
+	}
+
+	balance := book.Qty + movement.Quantity
+	if balance < 0 {
+		return &InsufficientStockError{BookID: movement.BookID, Title: book.Title, Available: book.Qty, Requested: -movement.Quantity}
+	}
+
+	// Stock changes are not edits of the book, the version and the ETag stay the same
+	if err := tx.Unscoped().Model(&models.Book{}).Where("id = ?", movement.BookID).UpdateColumn("qty", balance).Error; err != nil {
+		return err
+	}
+
+	movement.BalanceAfter = balance
+	return tx.Create(movement).Error
+}
+
+// MoveOrderBooks records a sale or a return of one copy of each of the books of an order
+func MoveOrderBooks(tx *gorm.DB, orderID uint, bookIDs []uint, movementType string, userID *uint) error {
+	quantity, err := SignedQuantity(movementType, 1)
+	if err != nil {
+		return err
+	}
+
+	// Locking the books in the same order avoids deadlocks between orders sharing books
+	ids := append([]uint(nil), bookIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		movement := models.StockMovement{
+			BookID:   id,
+			Type:     movementType,
+			Quantity: quantity,
+			Reason:   fmt.Sprintf("Order #%d", orderID),
+			OrderID:  &orderID,
+			UserID:   userID,
+		}
+
+		if err := Move(tx, &movement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Balance sums the movements of a book, it equals Book.Qty unless the stock was changed outside of Move
+func Balance(db *gorm.DB, bookID uint) (int, error) {
+	var balance int
+	err := db.Model(&models.StockMovement{}).Where("book_id = ?", bookID).Select("COALESCE(SUM(quantity), 0)").Scan(&balance).Error
+
+	return balance, err
+}
@@ -0,0 +1,13 @@
+package mailer
+
+import "log"
+
+// LogMailer prints emails instead of sending them, it is meant for development
+type LogMailer struct {
+	Logger *log.Logger
+}
+
+func (m LogMailer) Send(msg Message) error {
+	m.Logger.Printf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	return nil
+}
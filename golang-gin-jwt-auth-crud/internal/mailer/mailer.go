@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default is the mailer used by the controllers, it is replaced on startup by New
+var Default Mailer = LogMailer{Logger: log.New(os.Stdout, "[mail] ", log.LstdFlags)}
+
+// New returns the mailer for the given driver name ("smtp" or "log")
+func New(driver string) (Mailer, error) {
+	switch driver {
+	case "smtp":
+		return SMTPMailer{
+			Host:     config.GetString("SMTP_HOST", "localhost"),
+			Port:     config.GetInt("SMTP_PORT", 587),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     config.GetString("MAIL_FROM", "no-reply@localhost"),
+		}, nil
+	case "log":
+		return LogMailer{Logger: log.New(os.Stdout, "[mail] ", log.LstdFlags)}, nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", driver)
+	}
+}
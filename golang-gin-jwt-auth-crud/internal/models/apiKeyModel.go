@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// APIKey is a long-lived credential of a user for machine clients, only the hash of the key is stored
+type APIKey struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	UserID uint   `gorm:"index;not null" json:"user_id"`
+	Name   string `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix string `gorm:"type:varchar(32);index;not null" json:"prefix"`
+	// KeyHash is the SHA-256 of the whole key
+	KeyHash string `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	// Scopes are the space separated permissions the key may use
+	Scopes     string     `gorm:"type:text;not null" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// AuditLog records who changed a record and how
+type AuditLog struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// ActorID is the user who made the change, nil for anonymous requests such as a signup
+	ActorID *uint `gorm:"index" json:"actor_id"`
+	Actor   *User `gorm:"foreignKey:ActorID;constraint:-" json:"actor,omitempty"`
+	// APIKeyID is set when the change was made with an API key of the actor
+	APIKeyID     *uint        `json:"api_key_id"`
+	Action       string       `gorm:"type:varchar(32);index;not null" json:"action"`
+	ResourceType string       `gorm:"type:varchar(64);index:idx_audit_logs_resource;not null" json:"resource_type"`
+	ResourceID   uint         `gorm:"index:idx_audit_logs_resource" json:"resource_id"`
+	Changes      AuditChanges `gorm:"type:jsonb" json:"changes"`
+	IP           string       `gorm:"type:varchar(64)" json:"ip"`
+	CreatedAt    time.Time    `gorm:"index" json:"created_at"`
+}
+
+// AuditChange is the value of a field before and after a change
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditChanges maps the changed fields to their old and new values, it is stored as JSON
+type AuditChanges map[string]AuditChange
+
+// Value stores the changes as JSON
+func (a AuditChanges) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(a)
+	return string(b), err
+}
+
+// Scan reads the changes from JSON
+func (a *AuditChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	}
+
+	return errors.New("unsupported audit changes value")
+}
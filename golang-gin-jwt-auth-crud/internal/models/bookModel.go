@@ -1,27 +1,45 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type Book struct {
+	gorm.Model
 	ID       int    `gorm:"primaryKey"`
 	Title    string `gorm:"type:text" json:"title"`
 	Price    int    `gorm:"type:integer;default:0" json:"price"`
 	Category string `gorm:"type:text" json:"category"`
-	Qty      int    `gorm:"type:integer;default:0" json:"qty"`
+	// Qty is the balance of the stock movements of the book, it is only changed by the inventory package
+	Qty int `gorm:"type:integer;default:0" json:"qty"`
+	// Version is increased by every update and sent as the ETag
+	Version uint `gorm:"not null;default:1" json:"version"`
 }
 
 type BookRequest struct {
 	Title    string `query:"title" json:"title"`
 	Price    int    `query:"price" json:"price"`
 	Category string `query:"category" json:"category"`
-	Qty      int    `query:"qty" json:"qty"`
+	// Qty is the initial stock of a new book, later changes are posted as stock movements
+	Qty *int `query:"qty" json:"qty" binding:"omitempty,gte=0"`
 }
 
+// BookFilter declares the query parameters of the book list, see the filters package for the tags
 type BookFilter struct {
-	BookIDS  []int  `query:"book_ids" json:"book_ids"`
-	Title    string `query:"title" json:"title"`
-	Price    int    `query:"price" json:"price"`
-	Category string `query:"category" json:"category"`
-	Qty      int    `query:"qty" json:"qty"`
-	Page     int    `query:"page" json:"page"`
-	Limit    int    `query:"limit" json:"limit"`
-	Search   string `query:"search" json:"search"`
+	BookIDS   []int      `form:"book_ids" filter:"id,in" json:"book_ids"`
+	Title     string     `form:"title" filter:"title,eq,ne,like,in,sort" json:"title"`
+	TitleLike string     `form:"title_like" filter:"title,like" json:"title_like"`
+	Price     *int       `form:"price" filter:"price,eq,ne,lt,lte,gt,gte,in,between,sort" json:"price"`
+	PriceMin  *int       `form:"price_min" filter:"price,gte" json:"price_min"`
+	PriceMax  *int       `form:"price_max" filter:"price,lte" json:"price_max"`
+	Category  string     `form:"category" filter:"category,eq,ne,like,in,sort" json:"category"`
+	Qty       *int       `form:"qty" filter:"qty,eq,ne,lt,lte,gt,gte,in,between,sort" json:"qty"`
+	QtyMin    *int       `form:"qty_min" filter:"qty,gte" json:"qty_min"`
+	QtyMax    *int       `form:"qty_max" filter:"qty,lte" json:"qty_max"`
+	CreatedAt *time.Time `form:"created_at" filter:"created_at,gte,lt,between,sort" json:"created_at"`
+	Page      int        `form:"page" json:"page"`
+	Limit     int        `form:"limit" json:"limit"`
+	Search    string     `form:"search" filter:"title|category,like" json:"search"`
 }
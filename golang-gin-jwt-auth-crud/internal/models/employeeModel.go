@@ -1,14 +1,17 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// Employee names and emails are only unique among the employees that are not soft deleted
 type Employee struct {
 	gorm.Model
 	ID            int    `gorm:"primaryKey"`
-	Name          string `gorm:"unique;not null" json:"name"`
-	Email         string `gorm:"unique;not null" json:"email"`
+	Name          string `gorm:"uniqueIndex:idx_employees_name,where:deleted_at IS NULL;not null" json:"name"`
+	Email         string `gorm:"uniqueIndex:idx_employees_email,where:deleted_at IS NULL;not null" json:"email"`
 	Address       string `gorm:"type:varchar(255)" json:"address"`
 	Status        string `json:"status" gorm:"type:varchar(255)"`
 	Handphone     string `gorm:"type:varchar(14)" json:"handphone"`
@@ -16,6 +19,8 @@ type Employee struct {
 	BirthPlace    string `gorm:"type:varchar(255)" json:"birth_place"`
 	BirthDate     string `gorm:"type:date;not null" json:"birth_date"`
 	MaritalStatus string `gorm:"type:varchar(255)" json:"marital_status"`
+	// Version is increased by every update and sent as the ETag
+	Version uint `gorm:"not null;default:1" json:"version"`
 }
 
 type EmployeeRequest struct {
@@ -30,18 +35,20 @@ type EmployeeRequest struct {
 	MaritalStatus string `query:"marital_status" json:"marital_status"`
 }
 
+// EmployeeFilter declares the query parameters of the employee list, see the filters package for the tags
 type EmployeeFilter struct {
-	EmployeeIDS   []int  `query:"employee_ids" json:"employee_ids"`
-	Name          string `query:"name" json:"name"`
-	Email         string `query:"email" json:"email"`
-	Address       string `query:"address" json:"address"`
-	Status        string `query:"status" json:"status"`
-	Handphone     string `query:"handphone" json:"handphone"`
-	Gender        string `query:"gender" json:"gender"`
-	BirthPlace    string `query:"birth_place" json:"birth_place"`
-	BirthDate     string `query:"birth_date" json:"birth_date"`
-	MaritalStatus string `query:"marital_status" json:"marital_status"`
-	Page          int    `query:"page" json:"page"`
-	Limit         int    `query:"limit" json:"limit"`
-	Search        string `query:"search" json:"search"`
+	EmployeeIDS   []int      `form:"employee_ids" filter:"id,in" json:"employee_ids"`
+	Name          string     `form:"name" filter:"name,eq,ne,like,in,sort" json:"name"`
+	Email         string     `form:"email" filter:"email,eq,ne,like,in,sort" json:"email"`
+	Address       string     `form:"address" filter:"address,like,eq" json:"address"`
+	Status        string     `form:"status" filter:"status,eq,ne,in,sort" json:"status"`
+	Handphone     string     `form:"handphone" filter:"handphone,eq,like" json:"handphone"`
+	Gender        string     `form:"gender" filter:"gender,eq,ne,in,sort" json:"gender"`
+	BirthPlace    string     `form:"birth_place" filter:"birth_place,eq,ne,like,in,sort" json:"birth_place"`
+	BirthDate     string     `form:"birth_date" filter:"birth_date,date,eq,lt,lte,gt,gte,between,sort" json:"birth_date"`
+	MaritalStatus string     `form:"marital_status" filter:"marital_status,eq,ne,in,sort" json:"marital_status"`
+	CreatedAt     *time.Time `form:"created_at" filter:"created_at,gte,lt,between,sort" json:"created_at"`
+	Page          int        `form:"page" json:"page"`
+	Limit         int        `form:"limit" json:"limit"`
+	Search        string     `form:"search" filter:"name|email|handphone,like" json:"search"`
 }
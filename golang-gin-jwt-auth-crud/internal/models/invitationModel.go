@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type Invitation struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Email       string     `gorm:"index;not null" json:"email"`
+	Role        string     `gorm:"type:varchar(64);not null" json:"role"`
+	TokenHash   string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	InvitedByID uint       `gorm:"index" json:"invited_by_id"`
+	InvitedBy   *User      `gorm:"foreignKey:InvitedByID;constraint:-" json:"invited_by,omitempty"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Pending reports whether the invitation can still be used to sign up
+func (i Invitation) Pending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && time.Now().Before(i.ExpiresAt)
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LoginAttempt counts the failed logins of an account or a client IP
+type LoginAttempt struct {
+	Key          string     `gorm:"type:varchar(255);primaryKey" json:"key"`
+	Failures     int        `gorm:"not null;default:0" json:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"`
+}
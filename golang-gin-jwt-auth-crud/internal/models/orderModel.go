@@ -1,20 +1,27 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Order struct {
 	gorm.Model
-	ID         int    `gorm:"primaryKey"`
-	EmployeeID int    `gorm:"foreignkey:EmployeeID" json:"employee_id"`
-	OrderDate  string `gorm:"type:date" json:"order_date"`
-	Books      []Book `json:"books" gorm:"many2many:order_books;"`
-	TotalPrice int    `gorm:"type:integer;default:0" json:"total_price"`
+	ID         int       `gorm:"primaryKey"`
+	EmployeeID int       `gorm:"foreignkey:EmployeeID" json:"employee_id"`
+	Employee   *Employee `gorm:"foreignKey:EmployeeID;constraint:-" json:"employee,omitempty"`
+	OrderDate  string    `gorm:"type:date" json:"order_date"`
+	Books      []Book    `json:"books" gorm:"many2many:order_books;"`
+	TotalPrice int       `gorm:"type:integer;default:0" json:"total_price"`
+	// Version is increased by every update and sent as the ETag
+	Version uint `gorm:"not null;default:1" json:"version"`
 }
 
 type OrderRequest struct {
 	EmployeeID int    `gorm:"foreignkey:EmployeeID" json:"employee_id"`
 	OrderDate  string `gorm:"type:date" json:"order_date"`
-	BookID     []int  `gorm:"not null" json:"book_id"`
+	BookID     []int  `gorm:"not null" json:"book_id" binding:"required,min=1,dive,gt=0"`
 }
 
 type OrderRespomse struct {
@@ -25,13 +32,16 @@ type OrderRespomse struct {
 	TotalPrice int    `gorm:"type:integer;default:0" json:"total_price"`
 }
 
+// OrderFilter declares the query parameters of the order list, see the filters package for the tags.
+// BookIDS and Search need the books and the employees, ListOrders applies them.
 type OrderFilter struct {
-	BookIDS    []int  `query:"book_ids" json:"book_ids"`
-	EmployeeID int    `query:"employee_id" json:"employee_id"`
-	OrderDate  string `query:"order_date" json:"order_date"`
-	BookID     []int  `query:"book_id" json:"book_id"`
-	TotalPrice int    `query:"total_price" json:"total_price"`
-	Page       int    `query:"page" json:"page"`
-	Limit      int    `query:"limit" json:"limit"`
-	Search     string `query:"search" json:"search"`
+	BookIDS    []int      `form:"book_ids" filter:"-" json:"book_ids"`
+	OrderIDS   []int      `form:"order_ids" filter:"id,in" json:"order_ids"`
+	EmployeeID *int       `form:"employee_id" filter:"employee_id,eq,in,sort" json:"employee_id"`
+	OrderDate  string     `form:"order_date" filter:"order_date,date,eq,lt,lte,gt,gte,between,sort" json:"order_date"`
+	TotalPrice *int       `form:"total_price" filter:"total_price,eq,ne,lt,lte,gt,gte,between,sort" json:"total_price"`
+	CreatedAt  *time.Time `form:"created_at" filter:"created_at,gte,lt,between,sort" json:"created_at"`
+	Page       int        `form:"page" json:"page"`
+	Limit      int        `form:"limit" json:"limit"`
+	Search     string     `form:"search" filter:"-" json:"search"`
 }
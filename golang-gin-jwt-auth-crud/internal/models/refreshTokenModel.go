@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RefreshToken struct {
+	gorm.Model
+	UserID       uint       `gorm:"index;not null" json:"user_id"`
+	FamilyID     string     `gorm:"type:varchar(64);index;not null" json:"family_id"`
+	TokenHash    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+}
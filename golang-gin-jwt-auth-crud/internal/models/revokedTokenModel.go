@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	JTI       string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"jti"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserTokenCutoff marks every token of the user issued before RevokedBefore as revoked
+type UserTokenCutoff struct {
+	UserID        uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	RevokedBefore time.Time `gorm:"not null" json:"revoked_before"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+type Role struct {
+	gorm.Model
+	Name        string       `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Description string       `gorm:"type:varchar(255)" json:"description"`
+	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions,omitempty"`
+}
+
+type Permission struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Description string `gorm:"type:varchar(255)" json:"description"`
+}
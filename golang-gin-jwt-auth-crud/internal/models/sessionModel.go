@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Session is a login of a user on a device, it lives as long as its refresh token family
+type Session struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	FamilyID   string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	UserAgent  string     `gorm:"type:varchar(512)" json:"user_agent"`
+	IP         string     `gorm:"type:varchar(64)" json:"ip"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
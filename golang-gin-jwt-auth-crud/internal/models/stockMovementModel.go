@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Stock movement types, receipts and returns add copies, sales remove them and adjustments do either
+const (
+	StockReceipt    = "receipt"
+	StockSale       = "sale"
+	StockReturn     = "return"
+	StockAdjustment = "adjustment"
+)
+
+// Reasons of the opening entry of a book, written by CreateBook for its initial stock and by the
+// migration for the stock of the books from before the ledger
+const (
+	StockInitialReason = "Initial stock"
+	StockOpeningReason = "Opening balance"
+)
+
+// StockMovement is an entry of the stock ledger of a book. Book.Qty is the balance after the latest
+// movement, both are only changed together by the inventory package.
+type StockMovement struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	BookID uint   `gorm:"index;not null" json:"book_id"`
+	Type   string `gorm:"type:varchar(20);not null" json:"type"`
+	// Quantity is signed, negative movements take copies out of the stock
+	Quantity     int       `gorm:"not null" json:"quantity"`
+	BalanceAfter int       `gorm:"not null" json:"balance_after"`
+	Reason       string    `gorm:"type:varchar(255);not null" json:"reason"`
+	OrderID      *uint     `gorm:"index" json:"order_id"`
+	UserID       *uint     `json:"user_id"`
+	User         *User     `gorm:"foreignKey:UserID;constraint:-" json:"user,omitempty"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+// Opening reports whether the movement is the opening entry of its book
+func (m StockMovement) Opening() bool {
+	return m.OrderID == nil && (m.Type == StockReceipt && m.Reason == StockInitialReason ||
+		m.Type == StockAdjustment && m.Reason == StockOpeningReason)
+}
+
+// StockMovementRequest is a movement posted by hand, sales are only made by orders
+type StockMovementRequest struct {
+	Type     string `json:"type" binding:"required,oneof=receipt return adjustment"`
+	Quantity int    `json:"quantity" binding:"required"`
+	Reason   string `json:"reason" binding:"required,max=255"`
+}
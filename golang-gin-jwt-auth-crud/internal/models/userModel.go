@@ -1,10 +1,42 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// User statuses, disabled users cannot log in and their tokens are rejected
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+)
+
+// User emails are only unique among the users that are not soft deleted, a deleted user does not block its address
 type User struct {
 	gorm.Model
-	Name     string `json:"name"`
-	Email    string `json:"email" gorm:"unique;not null"`
-	Password string `json:"-"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email" gorm:"uniqueIndex:idx_users_email,where:deleted_at IS NULL;not null"`
+	Password        string     `json:"-"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TOTPSecret      string     `json:"-" gorm:"type:varchar(64)"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at"`
+	TOTPLastStep    int64      `json:"-" gorm:"default:0"`
+	Roles           []Role     `json:"roles,omitempty" gorm:"many2many:user_roles;"`
+	Status          string     `json:"status" gorm:"type:varchar(20);not null;default:active"`
+	LastLoginAt     *time.Time `json:"last_login_at"`
+	// Version is increased by every profile update and sent as the ETag
+	Version uint `gorm:"not null;default:1" json:"version"`
+}
+
+// UserFilter declares the query parameters of the user list, see the filters package for the tags
+type UserFilter struct {
+	UserIDS   []uint     `form:"user_ids" filter:"id,in" json:"user_ids"`
+	Name      string     `form:"name" filter:"name,eq,ne,like,sort" json:"name"`
+	Email     string     `form:"email" filter:"email,eq,ne,like,in,sort" json:"email"`
+	Status    string     `form:"status" filter:"status,eq,ne,in,sort" json:"status"`
+	CreatedAt *time.Time `form:"created_at" filter:"created_at,gte,lt,between,sort" json:"created_at"`
+	Page      int        `form:"page" json:"page"`
+	PerPage   int        `form:"perPage" json:"perPage"`
+	Search    string     `form:"search" filter:"name|email,like" json:"search"`
 }
@@ -0,0 +1,55 @@
+package oidc
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+)
+
+// Providers are the identity providers users can sign in with, set on startup by LoadProviders
+var Providers = map[string]*Provider{}
+
+// Find returns the configured provider with the name
+func Find(name string) (*Provider, error) {
+	provider, ok := Providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	return provider, nil
+}
+
+// LoadProviders builds the providers listed in OIDC_PROVIDERS, e.g. "google,okta". Every provider is
+// configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and optionally
+// OIDC_<NAME>_REDIRECT_URL and OIDC_<NAME>_SCOPES.
+func LoadProviders() (map[string]*Provider, error) {
+	providers := make(map[string]*Provider)
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	for _, name := range config.GetList("OIDC_PROVIDERS", nil) {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		provider := &Provider{
+			Name:         name,
+			Issuer:       config.GetString(prefix+"ISSUER", ""),
+			ClientID:     config.GetString(prefix+"CLIENT_ID", ""),
+			ClientSecret: config.GetString(prefix+"CLIENT_SECRET", ""),
+			RedirectURL: config.GetString(prefix+"REDIRECT_URL",
+				config.GetString("APP_URL", "http://localhost:3000")+"/api/oidc/"+name+"/callback"),
+			Scopes:     config.GetList(prefix+"SCOPES", []string{"openid", "email", "profile"}),
+			HTTPClient: client,
+		}
+
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+		}
+
+		providers[name] = provider
+	}
+
+	return providers, nil
+}
@@ -0,0 +1,174 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// jwksRefreshInterval limits how often an unknown kid makes us fetch the provider keys again
+const jwksRefreshInterval = time.Minute
+
+// keySet holds the public keys of a provider by kid
+type keySet struct {
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// jsonWebKey is a public key of the provider JWKS
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// key returns the public key with the kid for the algorithm, the keys are fetched again when the
+// provider rotated them
+func (p *Provider) key(ctx context.Context, md *metadata, kid, alg string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	found := p.keys != nil && findKey(p.keys.keys, kid) != nil
+	if !found && (p.keys == nil || time.Since(p.keys.fetchedAt) > jwksRefreshInterval) {
+		keys, err := p.fetchKeys(ctx, md.JWKSURI)
+		if err != nil {
+			return nil, err
+		}
+		p.keys = keys
+	}
+
+	public := findKey(p.keys.keys, kid)
+	if public == nil {
+		return nil, fmt.Errorf("no key %q in the provider keys", kid)
+	}
+
+	var ok bool
+	switch {
+	case strings.HasPrefix(alg, "RS"):
+		_, ok = public.(*rsa.PublicKey)
+	case strings.HasPrefix(alg, "ES"):
+		_, ok = public.(*ecdsa.PublicKey)
+	case alg == "EdDSA":
+		_, ok = public.(ed25519.PublicKey)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("key %q cannot verify %s", kid, alg)
+	}
+
+	return public, nil
+}
+
+// findKey returns the key with the kid, or the only key when the token has no kid
+func findKey(keys map[string]interface{}, kid string) interface{} {
+	if kid == "" && len(keys) == 1 {
+		for _, public := range keys {
+			return public
+		}
+	}
+
+	return keys[kid]
+}
+
+// fetchKeys downloads and parses the signing keys of the provider, unsupported keys are skipped
+func (p *Provider) fetchKeys(ctx context.Context, jwksURI string) (*keySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.do(req, &jwks); err != nil {
+		return nil, fmt.Errorf("keys of %s: %w", p.Name, err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		if public, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = public
+		}
+	}
+
+	return &keySet{keys: keys, fetchedAt: time.Now()}, nil
+}
+
+// publicKey decodes an RSA, EC or OKP key
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
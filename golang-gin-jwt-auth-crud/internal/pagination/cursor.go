@@ -0,0 +1,259 @@
+package pagination
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not made by PaginateCursor for the same sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor directions
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+// CursorParams selects the pagination of a list. Embedded next to the filters of the list, "pagination=cursor" or a
+// cursor switches the list from pages to cursors and "with_total=true" also counts the records.
+type CursorParams struct {
+	Pagination string `form:"pagination" json:"pagination"`
+	Cursor     string `form:"cursor" json:"cursor"`
+	WithTotal  bool   `form:"with_total" json:"with_total"`
+}
+
+// UsesCursor reports whether the request asked for cursor pagination
+func (p CursorParams) UsesCursor() bool {
+	return p.Pagination == "cursor" || p.Cursor != ""
+}
+
+// CursorResult is a slice of records with the cursors of the slices before and after it
+type CursorResult struct {
+	Data       interface{} `json:"data"`
+	PerPage    int         `json:"per_page"`
+	NextCursor string      `json:"next_cursor"`
+	PrevCursor string      `json:"prev_cursor"`
+	Total      *int64      `json:"total,omitempty"`
+}
+
+// cursor is the decoded form of the opaque cursor, the sort keys and the id of the record it points at
+type cursor struct {
+	Sort      string            `json:"s"`
+	Direction string            `json:"d"`
+	Values    []json.RawMessage `json:"v"`
+}
+
+var schemas sync.Map
+
+// orderColumns returns the order of the query, the id is added as the last column to make it unique
+func orderColumns(query *gorm.DB) ([]clause.OrderByColumn, error) {
+	var columns []clause.OrderByColumn
+
+	if c, ok := query.Statement.Clauses["ORDER BY"]; ok {
+		if orderBy, ok := c.Expression.(clause.OrderBy); ok {
+			columns = append(columns, orderBy.Columns...)
+		}
+	}
+
+	for _, column := range columns {
+		if column.Column.Raw || column.Column.Table != "" {
+			return nil, errors.New("cursor pagination needs an order by plain columns")
+		}
+
+		if column.Column.Name == "id" {
+			return columns, nil
+		}
+	}
+
+	return append(columns, clause.OrderByColumn{Column: clause.Column{Name: "id"}}), nil
+}
+
+// sortSignature names the order, a cursor only works with the order it was made for
+func sortSignature(columns []clause.OrderByColumn) string {
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column.Desc {
+			names = append(names, "-"+column.Column.Name)
+		} else {
+			names = append(names, column.Column.Name)
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+// encodeCursor makes the cursor pointing at the record
+func encodeCursor(s *schema.Schema, columns []clause.OrderByColumn, direction string, record reflect.Value) (string, error) {
+	c := cursor{Sort: sortSignature(columns), Direction: direction}
+
+	for _, column := range columns {
+		field := s.LookUpField(column.Column.Name)
+		if field == nil {
+			return "", errors.New("cursor pagination cannot sort by " + column.Column.Name)
+		}
+
+		value, _ := field.ValueOf(context.Background(), record)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, raw)
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor reads the cursor and converts its values to the types of the sort columns
+func decodeCursor(s *schema.Schema, columns []clause.OrderByColumn, token string) (string, []interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return "", nil, ErrInvalidCursor
+	}
+
+	if c.Sort != sortSignature(columns) || len(c.Values) != len(columns) || (c.Direction != cursorNext && c.Direction != cursorPrev) {
+		return "", nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		field := s.LookUpField(column.Column.Name)
+		if field == nil {
+			return "", nil, ErrInvalidCursor
+		}
+
+		value := reflect.New(field.FieldType)
+		if err := json.Unmarshal(c.Values[i], value.Interface()); err != nil {
+			return "", nil, ErrInvalidCursor
+		}
+		values[i] = value.Elem().Interface()
+	}
+
+	return c.Direction, values, nil
+}
+
+// keysetCondition selects the records after the cursor values in the order, or before them when backwards:
+// (a > 1) OR (a = 1 AND b > 2) OR (a = 1 AND b = 2 AND id > 3)
+func keysetCondition(columns []clause.OrderByColumn, values []interface{}, backwards bool) clause.Expression {
+	var alternatives []clause.Expression
+
+	for i, column := range columns {
+		var and []clause.Expression
+		for j := 0; j < i; j++ {
+			and = append(and, clause.Eq{Column: clause.Column{Name: columns[j].Column.Name}, Value: values[j]})
+		}
+
+		if column.Desc != backwards {
+			and = append(and, clause.Lt{Column: clause.Column{Name: column.Column.Name}, Value: values[i]})
+		} else {
+			and = append(and, clause.Gt{Column: clause.Column{Name: column.Column.Name}, Value: values[i]})
+		}
+
+		alternatives = append(alternatives, clause.And(and...))
+	}
+
+	return clause.Or(alternatives...)
+}
+
+// PaginateCursor loads limit records after the cursor, or the first records for an empty cursor, in the
+// order set by rawFunc. Unlike Paginate it neither counts nor skips rows, unless withTotal asks for the count.
+// output is a pointer to a slice of the model.
+func PaginateCursor(db *gorm.DB, token string, limit int, withTotal bool, rawFunc func(*gorm.DB) *gorm.DB, output interface{}) (CursorResult, error) {
+	s, err := schema.Parse(output, &schemas, db.NamingStrategy)
+	if err != nil {
+		return CursorResult{}, err
+	}
+
+	query := db.Model(output)
+	if rawFunc != nil {
+		query = rawFunc(query)
+	}
+
+	columns, err := orderColumns(query)
+	if err != nil {
+		return CursorResult{}, err
+	}
+
+	result := CursorResult{Data: output, PerPage: limit}
+
+	if withTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return CursorResult{}, err
+		}
+		result.Total = &total
+	}
+
+	backwards := false
+	if token != "" {
+		direction, values, err := decodeCursor(s, columns, token)
+		if err != nil {
+			return CursorResult{}, err
+		}
+
+		backwards = direction == cursorPrev
+		query = query.Where(keysetCondition(columns, values, backwards))
+	}
+
+	// Going backwards reads the records before the cursor in the reverse order
+	order := make([]clause.OrderByColumn, len(columns))
+	for i, column := range columns {
+		order[i] = clause.OrderByColumn{Column: column.Column, Desc: column.Desc != backwards}
+	}
+	order[0].Reorder = true
+
+	// One more record tells whether there is another slice
+	if err := query.Clauses(clause.OrderBy{Columns: order}).Limit(limit + 1).Find(output).Error; err != nil {
+		return CursorResult{}, err
+	}
+
+	records := reflect.ValueOf(output).Elem()
+	hasMore := records.Len() > limit
+	if hasMore {
+		records.Set(records.Slice(0, limit))
+	}
+
+	if backwards {
+		swap := reflect.Swapper(records.Interface())
+		for i, j := 0, records.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	if records.Len() == 0 {
+		return result, nil
+	}
+
+	// There are records after this slice when more were found going forwards or when coming back from them
+	if (!backwards && hasMore) || (backwards && token != "") {
+		if result.NextCursor, err = encodeCursor(s, columns, cursorNext, records.Index(records.Len()-1)); err != nil {
+			return CursorResult{}, err
+		}
+	}
+
+	if (backwards && hasMore) || (!backwards && token != "") {
+		if result.PrevCursor, err = encodeCursor(s, columns, cursorPrev, records.Index(0)); err != nil {
+			return CursorResult{}, err
+		}
+	}
+
+	return result, nil
+}
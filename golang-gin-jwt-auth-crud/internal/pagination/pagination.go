@@ -1,6 +1,67 @@
 package pagination
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidLimit is returned by Paginate for a page size below one
+var ErrInvalidLimit = errors.New("pagination: the limit must be at least 1")
+
+// PageParams are the page number and the page size of a list
+type PageParams struct {
+	Page  int
+	Limit int
+}
+
+// MaxLimit is the largest page size a client can ask for, set with PAGINATION_MAX_LIMIT
+func MaxLimit() int {
+	if limit := config.GetInt("PAGINATION_MAX_LIMIT", 100); limit > 0 {
+		return limit
+	}
+
+	return 100
+}
+
+// ParsePageParams reads the page and the page size given in the query string, empty values use page 1
+// and defaultLimit. Invalid values are returned as validation messages keyed by "page" or limitParam.
+func ParsePageParams(page, limit, limitParam string, defaultLimit int) (PageParams, map[string]interface{}) {
+	maxLimit := MaxLimit()
+	params := PageParams{Page: 1, Limit: defaultLimit}
+	invalid := make(map[string]interface{})
+
+	if page = strings.TrimSpace(page); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			invalid["page"] = "page must be a whole number of at least 1"
+		}
+		params.Page = n
+	}
+
+	if limit = strings.TrimSpace(limit); limit != "" {
+		n, err := strconv.Atoi(limit)
+		switch {
+		case err != nil || n < 1:
+			invalid[limitParam] = limitParam + " must be a whole number of at least 1"
+		case n > maxLimit:
+			invalid[limitParam] = limitParam + " must not be greater than " + strconv.Itoa(maxLimit)
+		}
+		params.Limit = n
+	} else if params.Limit > maxLimit {
+		// The default of a list is lowered to a smaller configured maximum
+		params.Limit = maxLimit
+	}
+
+	if len(invalid) > 0 {
+		return params, invalid
+	}
+
+	return params, nil
+}
 
 // PaginateResult represents the pagination metadata and data
 type PaginateResult struct {
@@ -13,21 +74,32 @@ type PaginateResult struct {
 	Total       int64       `json:"total"`
 }
 
-// Paginate returns a function that performs pagination on GORM queries
+// Paginate loads one page of the query into output and counts all its records. page starts at 1,
+// the errors of the count and of the query are returned.
 func Paginate(db *gorm.DB, page, limit int, rawFunc func(*gorm.DB) *gorm.DB, output interface{}) (PaginateResult, error) {
+	if limit < 1 {
+		return PaginateResult{}, ErrInvalidLimit
+	}
+
+	if page < 1 {
+		page = 1
+	}
+
 	offset := (page - 1) * limit
 
-	query := db
+	query := db.Model(output)
 	if rawFunc != nil {
 		query = rawFunc(query)
 	}
 
 	var total int64
-	query.Model(output).Count(&total)
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return PaginateResult{}, err
+	}
 
 	err := query.Offset(offset).Limit(limit).Find(output).Error
 	if err != nil {
-		return PaginateResult{}, nil
+		return PaginateResult{}, err
 	}
 
 	to := offset + limit
@@ -35,10 +107,16 @@ func Paginate(db *gorm.DB, page, limit int, rawFunc func(*gorm.DB) *gorm.DB, out
 		to = int(total)
 	}
 
+	// A page after the last one is empty
+	from := offset + 1
+	if from > to {
+		from, to = 0, 0
+	}
+
 	return PaginateResult{
 		Data:        output,
 		CurrentPage: page,
-		From:        offset + 1,
+		From:        from,
 		To:          to,
 		LastPage:    (int(total) + limit - 1) / limit,
 		PerPage:     limit,
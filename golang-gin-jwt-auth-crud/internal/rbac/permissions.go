@@ -0,0 +1,75 @@
+package rbac
+
+// Permissions are named "<resource>:<action>", "delete" soft deletes and "force-delete" deletes permanently.
+// UsersUpdate and UsersDelete are needed to change other users, everybody can change their own account.
+const (
+	UsersRead        = "users:read"
+	UsersCreate      = "users:create"
+	UsersUpdate      = "users:update"
+	UsersDelete      = "users:delete"
+	UsersForceDelete = "users:force-delete"
+	UsersUnlock      = "users:unlock"
+	UsersRestore     = "users:restore"
+	UsersDisable     = "users:disable"
+	UsersLogout      = "users:logout"
+
+	BooksRead        = "books:read"
+	BooksCreate      = "books:create"
+	BooksUpdate      = "books:update"
+	BooksDelete      = "books:delete"
+	BooksForceDelete = "books:force-delete"
+	BooksRestore     = "books:restore"
+	BooksStock       = "books:stock"
+
+	EmployeesRead        = "employees:read"
+	EmployeesCreate      = "employees:create"
+	EmployeesUpdate      = "employees:update"
+	EmployeesDelete      = "employees:delete"
+	EmployeesForceDelete = "employees:force-delete"
+	EmployeesRestore     = "employees:restore"
+
+	OrdersRead        = "orders:read"
+	OrdersCreate      = "orders:create"
+	OrdersUpdate      = "orders:update"
+	OrdersDelete      = "orders:delete"
+	OrdersForceDelete = "orders:force-delete"
+	OrdersRestore     = "orders:restore"
+
+	InvitationsRead   = "invitations:read"
+	InvitationsCreate = "invitations:create"
+	InvitationsRevoke = "invitations:revoke"
+
+	RolesRead   = "roles:read"
+	RolesManage = "roles:manage"
+	RolesAssign = "roles:assign"
+
+	AuditRead = "audit:read"
+)
+
+// Default role names
+const (
+	RoleAdmin = "admin"
+	RoleClerk = "clerk"
+)
+
+// AllPermissions lists every permission known to the application
+var AllPermissions = []string{
+	UsersRead, UsersCreate, UsersUpdate, UsersDelete, UsersForceDelete, UsersUnlock, UsersRestore,
+	UsersDisable, UsersLogout,
+	BooksRead, BooksCreate, BooksUpdate, BooksDelete, BooksForceDelete, BooksRestore, BooksStock,
+	EmployeesRead, EmployeesCreate, EmployeesUpdate, EmployeesDelete, EmployeesForceDelete, EmployeesRestore,
+	OrdersRead, OrdersCreate, OrdersUpdate, OrdersDelete, OrdersForceDelete, OrdersRestore,
+	InvitationsRead, InvitationsCreate, InvitationsRevoke,
+	RolesRead, RolesManage, RolesAssign,
+	AuditRead,
+}
+
+// DefaultRoles are created by Seed with the listed permissions
+var DefaultRoles = map[string][]string{
+	RoleAdmin: AllPermissions,
+	RoleClerk: {
+		BooksRead, BooksCreate, BooksUpdate, BooksStock,
+		EmployeesRead, EmployeesCreate, EmployeesUpdate,
+		OrdersRead, OrdersCreate, OrdersUpdate,
+	},
+}
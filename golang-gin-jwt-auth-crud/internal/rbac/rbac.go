@@ -0,0 +1,108 @@
+package rbac
+
+import (
+	"fmt"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"gorm.io/gorm"
+)
+
+// DefaultSignupRole returns the role given to users who sign up themselves
+func DefaultSignupRole() string {
+	return config.GetString("DEFAULT_ROLE", RoleClerk)
+}
+
+// Seed creates the known permissions and default roles, it is safe to run more than once.
+// Permissions are only added to existing roles, never removed.
+func Seed(db *gorm.DB) error {
+	permissions := make(map[string]models.Permission)
+	for _, name := range AllPermissions {
+		permission := models.Permission{Name: name}
+		if err := db.Where(models.Permission{Name: name}).FirstOrCreate(&permission).Error; err != nil {
+			return err
+		}
+		permissions[name] = permission
+	}
+
+	for roleName, permissionNames := range DefaultRoles {
+		role := models.Role{Name: roleName}
+		if err := db.Where(models.Role{Name: roleName}).FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+
+		var rolePermissions []models.Permission
+		for _, name := range permissionNames {
+			rolePermissions = append(rolePermissions, permissions[name])
+		}
+
+		if err := db.Model(&role).Association("Permissions").Append(rolePermissions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindRoles returns the roles with the given names or an error naming the first unknown role
+func FindRoles(db *gorm.DB, names []string) ([]models.Role, error) {
+	var roles []models.Role
+	if len(names) == 0 {
+		return roles, nil
+	}
+
+	if err := db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("the role %s does not exist", name)
+		}
+	}
+
+	return roles, nil
+}
+
+// RoleAndPermissionNames flattens the preloaded roles of a user into role and unique permission names
+func RoleAndPermissionNames(user models.User) ([]string, []string) {
+	roles := make([]string, 0, len(user.Roles))
+	permissions := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, role := range user.Roles {
+		roles = append(roles, role.Name)
+		for _, permission := range role.Permissions {
+			if !seen[permission.Name] {
+				seen[permission.Name] = true
+				permissions = append(permissions, permission.Name)
+			}
+		}
+	}
+
+	return roles, permissions
+}
+
+// ScopedPermissions returns the permissions that are both in the scopes of an API key and in the
+// permissions of its owner, so a key never outlives a role the owner lost
+func ScopedPermissions(scopes, permissions []string) []string {
+	granted := make(map[string]bool)
+	for _, permission := range permissions {
+		granted[permission] = true
+	}
+
+	scoped := make([]string, 0)
+	for _, scope := range scopes {
+		if granted[scope] {
+			scoped = append(scoped, scope)
+			granted[scope] = false
+		}
+	}
+
+	return scoped
+}
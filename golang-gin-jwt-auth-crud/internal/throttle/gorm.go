@@ -0,0 +1,72 @@
+package throttle
+
+import (
+	"errors"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormStore keeps the attempts in the login_attempts table so every instance sees them
+type GormStore struct {
+	DB *gorm.DB
+}
+
+func toAttempt(row models.LoginAttempt) Attempt {
+	attempt := Attempt{Key: row.Key, Failures: row.Failures, LastFailedAt: row.LastFailedAt}
+	if row.LockedUntil != nil {
+		attempt.LockedUntil = *row.LockedUntil
+	}
+
+	return attempt
+}
+
+func (s *GormStore) Get(key string) (Attempt, error) {
+	var row models.LoginAttempt
+	err := s.DB.First(&row, "key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Attempt{Key: key}, nil
+	}
+
+	return toAttempt(row), err
+}
+
+func (s *GormStore) RegisterFailure(key string, now time.Time, window time.Duration) (Attempt, error) {
+	var row models.LoginAttempt
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, then lock it
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginAttempt{Key: key, LastFailedAt: now}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&row, "key = ?", key).Error; err != nil {
+			return err
+		}
+
+		if now.Sub(row.LastFailedAt) > window {
+			row.Failures = 0
+		}
+
+		row.Failures++
+		row.LastFailedAt = now
+
+		return tx.Model(&row).Updates(map[string]interface{}{
+			"failures":       row.Failures,
+			"last_failed_at": row.LastFailedAt,
+		}).Error
+	})
+
+	return toAttempt(row), err
+}
+
+func (s *GormStore) Lock(key string, until time.Time) error {
+	return s.DB.Model(&models.LoginAttempt{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+func (s *GormStore) Reset(key string) error {
+	return s.DB.Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
@@ -0,0 +1,30 @@
+package throttle
+
+import (
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+)
+
+// Accounts and IPs limit failed logins per email address and per client IP, they are replaced on startup by UseStore
+var (
+	Accounts = newLoginLimiter(NewMemoryStore(), "account:", "LOGIN_MAX_ATTEMPTS", 5)
+	IPs      = newLoginLimiter(NewMemoryStore(), "ip:", "LOGIN_MAX_ATTEMPTS_PER_IP", 20)
+)
+
+// UseStore configures the login limiters from the environment and makes them use the store
+func UseStore(store Store) {
+	Accounts = newLoginLimiter(store, "account:", "LOGIN_MAX_ATTEMPTS", 5)
+	IPs = newLoginLimiter(store, "ip:", "LOGIN_MAX_ATTEMPTS_PER_IP", 20)
+}
+
+func newLoginLimiter(store Store, prefix, maxAttemptsKey string, maxAttempts int) *Limiter {
+	return &Limiter{
+		Store:       store,
+		Prefix:      prefix,
+		MaxAttempts: config.GetInt(maxAttemptsKey, maxAttempts),
+		BaseLockout: config.GetDuration("LOGIN_LOCKOUT", time.Minute),
+		MaxLockout:  config.GetDuration("LOGIN_MAX_LOCKOUT", time.Hour),
+		Window:      config.GetDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+	}
+}
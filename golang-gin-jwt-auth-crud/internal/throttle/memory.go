@@ -0,0 +1,64 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the attempts in process memory, it is meant for tests and single instance setups
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempt
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: make(map[string]Attempt)}
+}
+
+func (s *MemoryStore) Get(key string) (Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		attempt.Key = key
+	}
+
+	return attempt, nil
+}
+
+func (s *MemoryStore) RegisterFailure(key string, now time.Time, window time.Duration) (Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok || now.Sub(attempt.LastFailedAt) > window {
+		attempt = Attempt{Key: key, LockedUntil: attempt.LockedUntil}
+	}
+
+	attempt.Failures++
+	attempt.LastFailedAt = now
+	s.attempts[key] = attempt
+
+	return attempt, nil
+}
+
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := s.attempts[key]
+	attempt.Key = key
+	attempt.LockedUntil = until
+	s.attempts[key] = attempt
+
+	return nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
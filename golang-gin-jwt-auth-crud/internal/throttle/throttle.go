@@ -0,0 +1,91 @@
+package throttle
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attempt is the failure count of one key, e.g. "account:john@doe.com" or "ip:127.0.0.1"
+type Attempt struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  time.Time
+}
+
+// Store persists the attempts
+type Store interface {
+	Get(key string) (Attempt, error)
+	// RegisterFailure atomically adds a failure, the count starts over when the last failure is older than window
+	RegisterFailure(key string, now time.Time, window time.Duration) (Attempt, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// Limiter locks a key out after MaxAttempts failures, doubling the lockout with every further failure
+type Limiter struct {
+	Store       Store
+	Prefix      string
+	MaxAttempts int
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	Window      time.Duration
+}
+
+// NewStore returns the store for the given driver name ("database" or "memory")
+func NewStore(driver string, db *gorm.DB) (Store, error) {
+	switch driver {
+	case "database":
+		return &GormStore{DB: db}, nil
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown throttle store %q", driver)
+	}
+}
+
+// RetryAfter returns how long the key is still locked out, zero when it is not
+func (l *Limiter) RetryAfter(key string) (time.Duration, error) {
+	attempt, err := l.Store.Get(l.Prefix + key)
+	if err != nil {
+		return 0, err
+	}
+
+	if wait := time.Until(attempt.LockedUntil); wait > 0 {
+		return wait, nil
+	}
+
+	return 0, nil
+}
+
+// Fail records a failure and returns the lockout it caused, zero when the key is not locked out yet
+func (l *Limiter) Fail(key string) (time.Duration, error) {
+	now := time.Now()
+
+	attempt, err := l.Store.RegisterFailure(l.Prefix+key, now, l.Window)
+	if err != nil || attempt.Failures < l.MaxAttempts {
+		return 0, err
+	}
+
+	lockout := l.lockoutFor(attempt.Failures)
+	return lockout, l.Store.Lock(l.Prefix+key, now.Add(lockout))
+}
+
+// Reset forgets the failures of the key, e.g. after a successful login or an admin unlock
+func (l *Limiter) Reset(key string) error {
+	return l.Store.Reset(l.Prefix + key)
+}
+
+func (l *Limiter) lockoutFor(failures int) time.Duration {
+	exponent := float64(failures - l.MaxAttempts)
+	lockout := time.Duration(float64(l.BaseLockout) * math.Pow(2, exponent))
+
+	if lockout <= 0 || lockout > l.MaxLockout {
+		return l.MaxLockout
+	}
+
+	return lockout
+}
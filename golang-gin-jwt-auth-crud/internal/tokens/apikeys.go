@@ -0,0 +1,39 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key so leaked keys are easy to find, e.g. by secret scanners
+const APIKeyPrefix = "bgk_"
+
+// NewAPIKey returns a random API key for the client, its visible prefix and the hash to store in its place.
+// Keys look like "bgk_1a2b3c4d.<secret>", the part before the dot is the prefix shown in listings.
+func NewAPIKey() (raw, prefix, hash string, err error) {
+	id := make([]byte, 4)
+	if _, err = rand.Read(id); err != nil {
+		return "", "", "", err
+	}
+
+	secret, err := randomString(32)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	prefix = APIKeyPrefix + hex.EncodeToString(id)
+	raw = prefix + "." + secret
+
+	return raw, prefix, HashToken(raw), nil
+}
+
+// APIKeyPrefixOf returns the visible prefix of an API key, or false when it is not shaped like one
+func APIKeyPrefixOf(raw string) (string, bool) {
+	prefix, secret, found := strings.Cut(raw, ".")
+	if !found || secret == "" || !strings.HasPrefix(prefix, APIKeyPrefix) {
+		return "", false
+	}
+
+	return prefix, true
+}
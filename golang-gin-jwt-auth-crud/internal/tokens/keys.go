@@ -0,0 +1,246 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Keys signs and verifies every token, it is set on startup by LoadKeySet.
+// When it is nil tokens are signed with HS256 and the SECRET env variable.
+var Keys *KeySet
+
+// key is a signing or verification key identified by the kid header
+type key struct {
+	id     string
+	method jwt.SigningMethod
+	// private signs tokens: []byte for HMAC, *rsa.PrivateKey or ed25519.PrivateKey, nil for verification only keys
+	private interface{}
+	// public verifies tokens: []byte for HMAC, *rsa.PublicKey or ed25519.PublicKey
+	public interface{}
+}
+
+// KeySet holds the key used to sign new tokens and every key that is still accepted during a rotation
+type KeySet struct {
+	signing *key
+	keys    map[string]*key
+}
+
+// LoadKeySet builds the key set from the environment:
+//   - JWT_ALG: HS256 (default, uses SECRET), RS256 or EdDSA
+//   - JWT_PRIVATE_KEY_FILE: PEM private key used to sign tokens with RS256 or EdDSA
+//   - JWT_KEY_ID: kid of the signing key, derived from the public key when empty
+//   - JWT_PUBLIC_KEY_FILES: "kid=path,kid=path" of PEM public keys that are still accepted, e.g. the previous key
+func LoadKeySet() (*KeySet, error) {
+	alg := config.GetString("JWT_ALG", "HS256")
+
+	var (
+		signing *key
+		err     error
+	)
+
+	switch alg {
+	case "HS256":
+		secret := os.Getenv("SECRET")
+		if secret == "" {
+			return nil, errors.New("SECRET is required for HS256")
+		}
+		signing = hmacKey(secret)
+	case "RS256", "EdDSA":
+		signing, err = loadPrivateKey(os.Getenv("JWT_PRIVATE_KEY_FILE"), os.Getenv("JWT_KEY_ID"))
+		if err != nil {
+			return nil, err
+		}
+
+		if signing.method.Alg() != alg {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE holds a %s key but JWT_ALG is %s", signing.method.Alg(), alg)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALG %q", alg)
+	}
+
+	keySet := &KeySet{
+		signing: signing,
+		keys:    map[string]*key{signing.id: signing},
+	}
+
+	for _, entry := range config.GetList("JWT_PUBLIC_KEY_FILES", nil) {
+		kid, path, found := strings.Cut(entry, "=")
+		if !found || kid == "" || path == "" {
+			return nil, fmt.Errorf("invalid JWT_PUBLIC_KEY_FILES entry %q, expected kid=path", entry)
+		}
+
+		verification, err := loadPublicKey(path, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, exists := keySet.keys[kid]; !exists {
+			keySet.keys[kid] = verification
+		}
+	}
+
+	return keySet, nil
+}
+
+// activeKeys returns Keys or the HS256 fallback
+func activeKeys() *KeySet {
+	if Keys != nil {
+		return Keys
+	}
+
+	signing := hmacKey(os.Getenv("SECRET"))
+	return &KeySet{signing: signing, keys: map[string]*key{signing.id: signing}}
+}
+
+func hmacKey(secret string) *key {
+	return &key{id: "hs256", method: jwt.SigningMethodHS256, private: []byte(secret), public: []byte(secret)}
+}
+
+// Sign signs the claims with the current signing key and sets the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	token.Header["kid"] = ks.signing.id
+
+	return token.SignedString(ks.signing.private)
+}
+
+// Keyfunc finds the verification key from the kid header and checks that the algorithm matches
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = ks.signing.id
+	}
+
+	verification, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	// Validate the alg
+	if token.Method.Alg() != verification.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return verification.public, nil
+}
+
+// JWKS returns the public keys as a JSON Web Key Set, HMAC secrets are never published
+func (ks *KeySet) JWKS() map[string]interface{} {
+	jwks := make([]map[string]string, 0, len(ks.keys))
+
+	for _, k := range ks.keys {
+		switch public := k.public.(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, map[string]string{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": k.method.Alg(),
+				"kid": k.id,
+				"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks = append(jwks, map[string]string{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"use": "sig",
+				"alg": k.method.Alg(),
+				"kid": k.id,
+				"x":   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	return map[string]interface{}{"keys": jwks}
+}
+
+func loadPrivateKey(path, kid string) (*key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		// PKCS #1 is what "openssl genrsa" writes
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("%s: unsupported private key: %w", path, err)
+		}
+	}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		return newKey(kid, jwt.SigningMethodRS256, private, &private.PublicKey)
+	case ed25519.PrivateKey:
+		return newKey(kid, jwt.SigningMethodEdDSA, private, private.Public())
+	default:
+		return nil, fmt.Errorf("%s: only RSA and Ed25519 private keys are supported", path)
+	}
+}
+
+func loadPublicKey(path, kid string) (*key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unsupported public key: %w", path, err)
+	}
+
+	switch public := parsed.(type) {
+	case *rsa.PublicKey:
+		return newKey(kid, jwt.SigningMethodRS256, nil, public)
+	case ed25519.PublicKey:
+		return newKey(kid, jwt.SigningMethodEdDSA, nil, public)
+	default:
+		return nil, fmt.Errorf("%s: only RSA and Ed25519 public keys are supported", path)
+	}
+}
+
+// newKey derives the kid from the public key when none is configured
+func newKey(kid string, method jwt.SigningMethod, private, public interface{}) (*key, error) {
+	if kid == "" {
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(der)
+		kid = hex.EncodeToString(sum[:8])
+	}
+
+	return &key{id: kid, method: method, private: private, public: public}, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	if path == "" {
+		return nil, errors.New("key file path is empty")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	return block, nil
+}
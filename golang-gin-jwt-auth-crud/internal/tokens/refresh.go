@@ -0,0 +1,145 @@
+package tokens
+
+import (
+	"errors"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// RefreshTokenTTL returns how long a refresh token stays valid
+func RefreshTokenTTL() time.Duration {
+	return config.GetDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// IssueRefreshToken stores a new refresh token for the user and returns its raw value.
+// An empty familyID starts a new family, i.e. a new login.
+func IssueRefreshToken(db *gorm.DB, userID uint, familyID string) (string, error) {
+	raw, _, err := createRefreshToken(db, userID, familyID)
+	return raw, err
+}
+
+func createRefreshToken(db *gorm.DB, userID uint, familyID string) (string, models.RefreshToken, error) {
+	raw, err := randomString(32)
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+
+	if familyID == "" {
+		if familyID, err = randomString(16); err != nil {
+			return "", models.RefreshToken{}, err
+		}
+	}
+
+	refreshToken := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: HashToken(raw),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
+	}
+
+	if err := db.Create(&refreshToken).Error; err != nil {
+		return "", models.RefreshToken{}, err
+	}
+
+	return raw, refreshToken, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one of the same family and returns the new token.
+// Presenting a token that was already rotated or revoked revokes the whole family.
+func RotateRefreshToken(db *gorm.DB, raw string) (models.RefreshToken, string, error) {
+	var (
+		newRaw   string
+		newToken models.RefreshToken
+		reused   bool
+	)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", HashToken(raw)).
+			First(&current)
+
+		if err := result.Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		// A token that is no longer active is being replayed
+		if current.RevokedAt != nil {
+			reused = true
+			return revokeFamily(tx, current.FamilyID)
+		}
+
+		if time.Now().After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		next, nextToken, err := createRefreshToken(tx, current.UserID, current.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		err = tx.Model(&current).Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"replaced_by_id": nextToken.ID,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		newRaw = next
+		newToken = nextToken
+		return nil
+	})
+
+	if err != nil {
+		return models.RefreshToken{}, "", err
+	}
+
+	if reused {
+		return models.RefreshToken{}, "", ErrRefreshTokenReused
+	}
+
+	return newToken, newRaw, nil
+}
+
+// RevokeRefreshToken revokes the family the given refresh token belongs to, e.g. on logout
+func RevokeRefreshToken(db *gorm.DB, raw string) error {
+	var current models.RefreshToken
+	if err := db.Where("token_hash = ?", HashToken(raw)).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return revokeFamily(db, current.FamilyID)
+}
+
+// revokeFamily revokes the refresh tokens of a family and ends the session they belong to
+func revokeFamily(db *gorm.DB, familyID string) error {
+	now := time.Now()
+
+	err := db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
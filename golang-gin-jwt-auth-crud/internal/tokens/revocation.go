@@ -0,0 +1,136 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevocationStore keeps track of access tokens that must no longer be accepted
+type RevocationStore interface {
+	// Revoke revokes a single token until it would have expired anyway
+	Revoke(jti string, userID uint, expiresAt time.Time) error
+	// IsRevoked reports whether the token with the given jti was revoked
+	IsRevoked(jti string) (bool, error)
+	// RevokeAllForUser revokes every token of the user issued before the given time
+	RevokeAllForUser(userID uint, before time.Time) error
+	// RevokedBefore returns the cutoff set by RevokeAllForUser, or the zero time
+	RevokedBefore(userID uint) (time.Time, error)
+}
+
+// Revocations is the store used by RequireAuth, it is replaced on startup by NewRevocationStore
+var Revocations RevocationStore = NewMemoryRevocationStore()
+
+// NewRevocationStore returns the store for the given driver name ("database" or "memory")
+func NewRevocationStore(driver string, db *gorm.DB) (RevocationStore, error) {
+	switch driver {
+	case "database":
+		return &GormRevocationStore{DB: db}, nil
+	case "memory":
+		return NewMemoryRevocationStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown revocation store %q", driver)
+	}
+}
+
+// GormRevocationStore stores revocations in the revoked_tokens and user_token_cutoffs tables
+type GormRevocationStore struct {
+	DB *gorm.DB
+}
+
+func (s *GormRevocationStore) Revoke(jti string, userID uint, expiresAt time.Time) error {
+	// Expired entries are useless, clean them up while we are here
+	if err := s.DB.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	return s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+func (s *GormRevocationStore) IsRevoked(jti string) (bool, error) {
+	var count int64
+	err := s.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+
+	return count > 0, err
+}
+
+func (s *GormRevocationStore) RevokeAllForUser(userID uint, before time.Time) error {
+	return s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "updated_at"}),
+	}).Create(&models.UserTokenCutoff{
+		UserID:        userID,
+		RevokedBefore: before,
+	}).Error
+}
+
+func (s *GormRevocationStore) RevokedBefore(userID uint) (time.Time, error) {
+	var cutoff models.UserTokenCutoff
+	err := s.DB.First(&cutoff, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+
+	return cutoff.RevokedBefore, err
+}
+
+// MemoryRevocationStore keeps revocations in process memory, it is meant for tests and single instance setups
+type MemoryRevocationStore struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+	cutoffs map[uint]time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		revoked: make(map[string]time.Time),
+		cutoffs: make(map[uint]time.Time),
+	}
+}
+
+func (s *MemoryRevocationStore) Revoke(jti string, userID uint, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.revoked {
+		if exp.Before(now) {
+			delete(s.revoked, id)
+		}
+	}
+
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func (s *MemoryRevocationStore) RevokeAllForUser(userID uint, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cutoffs[userID] = before
+	return nil
+}
+
+func (s *MemoryRevocationStore) RevokedBefore(userID uint) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cutoffs[userID], nil
+}
@@ -0,0 +1,104 @@
+package tokens
+
+import (
+	"errors"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionTouchInterval returns how often the last seen time of a session is written
+func SessionTouchInterval() time.Duration {
+	return config.GetDuration("SESSION_TOUCH_INTERVAL", time.Minute)
+}
+
+// StartSession records a new login of the user and returns it with the first refresh token of its family
+func StartSession(db *gorm.DB, userID uint, userAgent, ip string) (models.Session, string, error) {
+	familyID, err := randomString(16)
+	if err != nil {
+		return models.Session{}, "", err
+	}
+
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	session := models.Session{
+		UserID:     userID,
+		FamilyID:   familyID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastSeenAt: time.Now(),
+	}
+
+	var refreshToken string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		refreshToken, err = IssueRefreshToken(tx, userID, familyID)
+		return err
+	})
+	if err != nil {
+		return models.Session{}, "", err
+	}
+
+	return session, refreshToken, nil
+}
+
+// GenerateSessionAccessToken signs an access token that belongs to a session
+func GenerateSessionAccessToken(userID, sessionID uint) (string, time.Time, error) {
+	return GenerateTypedToken(TypeAccess, userID, AccessTokenTTL(), jwt.MapClaims{"sid": sessionID})
+}
+
+// SessionID returns the session stored in the sid claim, 0 for tokens without a session
+func SessionID(claims jwt.MapClaims) uint {
+	sid, _ := claims["sid"].(float64)
+	return uint(sid)
+}
+
+// FindSessionByFamily returns the session of a refresh token family
+func FindSessionByFamily(db *gorm.DB, familyID string) (models.Session, error) {
+	var session models.Session
+	if err := db.Where("family_id = ?", familyID).Find(&session).Error; err != nil {
+		return session, err
+	}
+
+	if session.ID == 0 {
+		return session, ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+// TouchSession saves the last seen time and IP of the session, at most once per SessionTouchInterval
+func TouchSession(db *gorm.DB, session models.Session, ip string) error {
+	if time.Since(session.LastSeenAt) < SessionTouchInterval() && session.IP == ip {
+		return nil
+	}
+
+	return db.Model(&session).UpdateColumns(map[string]interface{}{
+		"last_seen_at": time.Now(),
+		"ip":           ip,
+	}).Error
+}
+
+// RevokeSession ends a session of the user, its refresh tokens and access tokens stop working
+func RevokeSession(db *gorm.DB, userID, sessionID uint) error {
+	var session models.Session
+	if err := db.Where("user_id = ?", userID).Find(&session, sessionID).Error; err != nil {
+		return err
+	}
+
+	if session.ID == 0 {
+		return ErrSessionNotFound
+	}
+
+	return revokeFamily(db, session.FamilyID)
+}
@@ -0,0 +1,178 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Token types carried in the "typ" claim, a token is only accepted where its type is expected
+const (
+	TypeAccess            = "access"
+	TypeEmailVerification = "email_verification"
+	TypeMFAPending        = "mfa_pending"
+	TypeOIDCState         = "oidc_state"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// AccessTokenTTL returns how long an access token stays valid
+func AccessTokenTTL() time.Duration {
+	return config.GetDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// GenerateAccessToken signs a short-lived access token for the user
+func GenerateAccessToken(userID uint) (string, time.Time, error) {
+	return GenerateTypedToken(TypeAccess, userID, AccessTokenTTL(), nil)
+}
+
+// ParseAccessToken validates the signature, expiry and type of an access token and returns its claims
+func ParseAccessToken(tokenString string) (jwt.MapClaims, error) {
+	return ParseTypedToken(TypeAccess, tokenString)
+}
+
+// GenerateTypedToken signs a token of the given type for the user, extra claims are added as they are
+func GenerateTypedToken(typ string, userID uint, ttl time.Duration, extra jwt.MapClaims) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	jti, err := randomString(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"jti": jti,
+		"typ": typ,
+		// Millisecond precision so a token issued right after "log out all sessions" stays valid
+		"iat": float64(now.UnixMilli()) / 1000,
+		"exp": expiresAt.Unix(),
+	}
+
+	for name, value := range extra {
+		claims[name] = value
+	}
+
+	tokenString, err := activeKeys().Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+// ParseTypedToken validates the signature, expiry and type of a token and returns its claims
+func ParseTypedToken(typ, tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, activeKeys().Keyfunc)
+
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	// jwt only validates exp when it is present, so require it here
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != typ || claims["exp"] == nil {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// UserID returns the user id stored in the sub claim
+func UserID(claims jwt.MapClaims) uint {
+	sub, _ := claims["sub"].(float64)
+	return uint(sub)
+}
+
+// IsRevoked checks the access token claims against the revocation store
+func IsRevoked(claims jwt.MapClaims) (bool, error) {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return true, nil
+	}
+
+	revoked, err := Revocations.IsRevoked(jti)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	cutoff, err := Revocations.RevokedBefore(UserID(claims))
+	if err != nil || cutoff.IsZero() {
+		return false, err
+	}
+
+	// claims.GetIssuedAt truncates to seconds, so read the milliseconds ourselves
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return true, nil
+	}
+
+	return time.UnixMilli(int64(iat * 1000)).Before(cutoff), nil
+}
+
+// RevokeToken revokes a single token until it expires, e.g. on logout or after a single use token was used
+func RevokeToken(claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || jti == "" || expiresAt == nil {
+		return ErrInvalidToken
+	}
+
+	return Revocations.Revoke(jti, UserID(claims), expiresAt.Time)
+}
+
+// RevokeAllSessions revokes every access and refresh token the user currently holds
+func RevokeAllSessions(db *gorm.DB, userID uint) error {
+	// Tokens carry iat in milliseconds, so a token issued right after this call is never older than the cutoff
+	if err := Revocations.RevokeAllForUser(userID, time.Now().Truncate(time.Millisecond)); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	err := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// randomString returns a url safe random string built from n random bytes
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewOpaqueToken returns a random token for the client and the hash to store in its place
+func NewOpaqueToken() (string, string, error) {
+	raw, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	return raw, HashToken(raw), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of an opaque token, which is what gets stored
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,51 @@
+package validations
+
+import (
+	"fmt"
+	"unicode"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
+)
+
+// ValidatePassword checks a new password against the configured policy and returns the rules it breaks.
+// The policy is set with PASSWORD_MIN_LENGTH and PASSWORD_REQUIRE_UPPER, _LOWER, _DIGIT and _SYMBOL.
+func ValidatePassword(password string) []string {
+	var errs []string
+
+	minLength := config.GetInt("PASSWORD_MIN_LENGTH", 10)
+	if len([]rune(password)) < minLength {
+		errs = append(errs, fmt.Sprintf("Password must have at least %d characters", minLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	if config.GetBool("PASSWORD_REQUIRE_UPPER", true) && !hasUpper {
+		errs = append(errs, "Password must contain an uppercase letter")
+	}
+
+	if config.GetBool("PASSWORD_REQUIRE_LOWER", true) && !hasLower {
+		errs = append(errs, "Password must contain a lowercase letter")
+	}
+
+	if config.GetBool("PASSWORD_REQUIRE_DIGIT", true) && !hasDigit {
+		errs = append(errs, "Password must contain a digit")
+	}
+
+	if config.GetBool("PASSWORD_REQUIRE_SYMBOL", false) && !hasSymbol {
+		errs = append(errs, "Password must contain a symbol")
+	}
+
+	return errs
+}
@@ -7,10 +7,12 @@ import (
 	"github.com/go-playground/validator/v10"
 )
 
+// IsUniqueValue reports whether a record other than IdNotIn has the value, soft deleted records
+// do not count so their values can be reused, which the partial unique indexes of the models allow
 func IsUniqueValue(tableName string, fieldName string, value string, IdNotIn int) bool {
 	var count int64
 
-	result := initializers.DB.Table(tableName).Where(fieldName+" = ?", value)
+	result := initializers.DB.Table(tableName).Where(fieldName+" = ? AND deleted_at IS NULL", value)
 	if IdNotIn > 0 { //for case update data
 		result = result.Where("id != ?", IdNotIn)
 	}
@@ -43,7 +45,7 @@ func FormatValidationErrors(errs validator.ValidationErrors) map[string]string {
 	for _, err := range errs {
 		fmt.Println()
 		switch err.Tag() {
-		case "required":
+		case "required", "required_without":
 			errorMessages[err.Field()] = fmt.Sprintf("%s is required", err.Field())
 		case "email":
 			errorMessages[err.Field()] = fmt.Sprintf("%s must be a valid email address", err.Field())
@@ -55,6 +57,8 @@ func FormatValidationErrors(errs validator.ValidationErrors) map[string]string {
 			errorMessages[err.Field()] = fmt.Sprintf("%s must be greater than %s", err.Field(), err.Param())
 		case "gte":
 			errorMessages[err.Field()] = fmt.Sprintf("%s must be greater than or equal to %s", err.Field(), err.Param())
+		case "oneof":
+			errorMessages[err.Field()] = fmt.Sprintf("%s must be one of %s", err.Field(), err.Param())
 		default:
 			errorMessages[err.Field()] = fmt.Sprintf("Validation validations on field %s", err.Field())
 		}
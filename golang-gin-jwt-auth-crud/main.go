@@ -2,15 +2,56 @@ package main
 
 import (
 	"fmt"
+	"log"
+
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/api/router"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/config"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/mailer"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/oidc"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/throttle"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
 	"github.com/gin-gonic/gin"
 )
 
 func init() {
 	config.LoadEnvVariables()
 	initializers.ConnectDB()
+
+	// Use the configured store for revoked tokens
+	store, err := tokens.NewRevocationStore(config.GetString("REVOCATION_STORE", "database"), initializers.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokens.Revocations = store
+
+	// Load the keys used to sign and verify tokens
+	keys, err := tokens.LoadKeySet()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokens.Keys = keys
+
+	// Use the configured mailer
+	mail, err := mailer.New(config.GetString("MAILER", "log"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	mailer.Default = mail
+
+	// Keep the failed logins in the configured store
+	attempts, err := throttle.NewStore(config.GetString("LOGIN_THROTTLE_STORE", "database"), initializers.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	throttle.UseStore(attempts)
+
+	// Load the identity providers users can sign in with
+	providers, err := oidc.LoadProviders()
+	if err != nil {
+		log.Fatal(err)
+	}
+	oidc.Providers = providers
 }
 
 func main() {
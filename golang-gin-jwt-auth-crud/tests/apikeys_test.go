@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	raw, prefix, hash, err := tokens.NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(raw, prefix+".") || !strings.HasPrefix(prefix, tokens.APIKeyPrefix) {
+		t.Fatalf("the key %q does not start with its prefix %q", raw, prefix)
+	}
+
+	if hash != tokens.HashToken(raw) || strings.Contains(hash, raw) {
+		t.Fatal("the stored hash must be the hash of the key")
+	}
+
+	if got, ok := tokens.APIKeyPrefixOf(raw); !ok || got != prefix {
+		t.Fatalf("APIKeyPrefixOf returned %q, %v", got, ok)
+	}
+
+	for _, invalid := range []string{"", "bgk_1234", "Bearer abc.def", "abc.def"} {
+		if _, ok := tokens.APIKeyPrefixOf(invalid); ok {
+			t.Fatalf("%q was accepted as an API key", invalid)
+		}
+	}
+}
+
+func TestScopedPermissions(t *testing.T) {
+	scopes := []string{rbac.OrdersCreate, rbac.OrdersRead, rbac.UsersDelete, rbac.OrdersCreate}
+	permissions := []string{rbac.OrdersRead, rbac.OrdersCreate, rbac.BooksRead}
+
+	got := rbac.ScopedPermissions(scopes, permissions)
+	want := []string{rbac.OrdersCreate, rbac.OrdersRead}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ScopedPermissions = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/api/router"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/mailer"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+// testPassword follows the default password policy
+const testPassword = "Secret-Pass-123"
+
+// newTestApp recreates a clean database and returns the router of the application
+func newTestApp(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	DatabaseRefresh()
+
+	r := gin.New()
+	router.GetRoute(r)
+
+	return r
+}
+
+// mailbox keeps the sent emails instead of sending them
+type mailbox struct {
+	messages []mailer.Message
+}
+
+func (m *mailbox) Send(msg mailer.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// useMailbox replaces the mailer for the test
+func useMailbox(t *testing.T) *mailbox {
+	box := &mailbox{}
+	previous := mailer.Default
+	mailer.Default = box
+	t.Cleanup(func() { mailer.Default = previous })
+
+	return box
+}
+
+var mailedTokenPattern = regexp.MustCompile(`token=(\S+)`)
+
+// mailedToken returns the token of the link in the last sent email
+func (m *mailbox) mailedToken(t *testing.T) string {
+	if len(m.messages) == 0 {
+		t.Fatal("no email was sent")
+	}
+
+	match := mailedTokenPattern.FindStringSubmatch(m.messages[len(m.messages)-1].Body)
+	if match == nil {
+		t.Fatalf("the email has no link with a token: %s", m.messages[len(m.messages)-1].Body)
+	}
+
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// performRequest sends the body as JSON, header may be nil
+func performRequest(r http.Handler, method, path string, body interface{}, header http.Header) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		reader = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+// bearer is the header of a request authenticated with the access token
+func bearer(accessToken string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + accessToken}}
+}
+
+// decodeBody reads the JSON response
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("the response is not JSON: %s", w.Body.String())
+	}
+
+	return body
+}
+
+// createTestUser creates a user with a verified email, the password testPassword and the roles
+func createTestUser(t *testing.T, email string, roleNames ...string) models.User {
+	hash, err := helpers.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles, err := rbac.FindRoles(initializers.DB, roleNames)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifiedAt := time.Now()
+	user := models.User{Name: "Test User", Email: email, Password: hash, EmailVerifiedAt: &verifiedAt, Roles: roles}
+	if err := initializers.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+// loginTestUser logs in with testPassword and returns the access and refresh tokens
+func loginTestUser(t *testing.T, r http.Handler, email string) (string, string) {
+	w := performRequest(r, http.MethodPost, "/api/login", gin.H{
+		"email":         email,
+		"password":      testPassword,
+		"token_in_body": true,
+	}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("the login of %s failed with %d: %s", email, w.Code, w.Body.String())
+	}
+
+	body := decodeBody(t, w)
+	accessToken, _ := body["access_token"].(string)
+	refreshToken, _ := body["refresh_token"].(string)
+
+	return accessToken, refreshToken
+}
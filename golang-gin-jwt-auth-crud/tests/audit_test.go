@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/audit"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+)
+
+func TestAuditDiffOfAnUpdate(t *testing.T) {
+	before := models.Book{ID: 1, Title: "Go", Price: 100, Category: "IT", Qty: 5}
+	before.UpdatedAt = time.Now().Add(-time.Hour)
+
+	after := before
+	after.Price = 120
+	after.UpdatedAt = time.Now()
+
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("expected only the price to change, got %v", changes)
+	}
+
+	if change := changes["price"]; change.Old != float64(100) || change.New != float64(120) {
+		t.Fatalf("unexpected price change %+v", change)
+	}
+}
+
+func TestAuditDiffOfACreateAndADelete(t *testing.T) {
+	book := models.Book{ID: 1, Title: "Go", Price: 100}
+
+	created, err := audit.Diff(nil, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change, ok := created["title"]; !ok || change.Old != nil || change.New != "Go" {
+		t.Fatalf("the created title is missing: %v", created)
+	}
+
+	deleted, err := audit.Diff(book, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change, ok := deleted["title"]; !ok || change.Old != "Go" || change.New != nil {
+		t.Fatalf("the deleted title is missing: %v", deleted)
+	}
+
+	if _, ok := deleted["CreatedAt"]; ok {
+		t.Fatal("timestamps must not be part of the changes")
+	}
+}
+
+func TestAuditDiffHidesSecrets(t *testing.T) {
+	before := models.User{Name: "Jane", Password: "old-hash", TOTPSecret: "old-secret"}
+	after := models.User{Name: "Jane", Password: "new-hash", TOTPSecret: "new-secret"}
+
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 0 {
+		t.Fatalf("fields hidden from JSON must not be recorded, got %v", changes)
+	}
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestFailedAuditEntryRollsTheWriteBack(t *testing.T) {
+	r := newTestApp(t)
+	createTestUser(t, "admin@example.com", rbac.RoleAdmin)
+	jane := createTestUser(t, "jane@example.com", rbac.RoleClerk)
+	accessToken, _ := loginTestUser(t, r, "admin@example.com")
+
+	book := models.Book{Title: "Go", Price: 100, Category: "IT"}
+	trashed := models.Book{Title: "Old", Price: 10, Category: "IT", Model: gorm.Model{DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}}}
+	if err := initializers.DB.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := initializers.DB.Create(&trashed).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	failQueries(t, initializers.DB.Callback().Create().Before("gorm:create"), "audit_logs")
+
+	cases := []struct {
+		name      string
+		method    string
+		path      string
+		body      interface{}
+		unchanged func() bool
+	}{
+		{"create", http.MethodPost, "/api/books/create", gin.H{"title": "New", "price": 5, "category": "IT"}, func() bool {
+			return initializers.DB.First(&models.Book{}, "title = ?", "New").Error != nil
+		}},
+		{"update", http.MethodPut, fmt.Sprintf("/api/books/update/%d", book.ID), gin.H{"title": "Renamed", "price": 5, "category": "IT"}, func() bool {
+			var current models.Book
+			initializers.DB.First(&current, book.ID)
+			return current.Title == "Go" && current.Version == book.Version
+		}},
+		{"restore", http.MethodPost, fmt.Sprintf("/api/books/%d/restore", trashed.ID), nil, func() bool {
+			return initializers.DB.First(&models.Book{}, trashed.ID).Error != nil
+		}},
+		{"role change", http.MethodPut, fmt.Sprintf("/api/users/%d/roles", jane.ID), gin.H{"roles": []string{rbac.RoleAdmin}}, func() bool {
+			var current models.User
+			initializers.DB.Preload("Roles").First(&current, jane.ID)
+			return len(current.Roles) == 1 && current.Roles[0].Name == rbac.RoleClerk
+		}},
+		{"disable", http.MethodPost, fmt.Sprintf("/api/users/%d/disable", jane.ID), nil, func() bool {
+			var current models.User
+			initializers.DB.First(&current, jane.ID)
+			return current.Status != models.UserStatusDisabled
+		}},
+	}
+
+	for _, tc := range cases {
+		w := performRequest(r, tc.method, tc.path, tc.body, bearer(accessToken))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected 500, got %d: %s", tc.name, w.Code, w.Body.String())
+		}
+
+		if !tc.unchanged() {
+			t.Errorf("%s: the change was kept without its audit entry", tc.name)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/filters"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
+)
+
+// cursorPageSQL returns the SQL of the book list for the query string in cursor mode
+func cursorPageSQL(t *testing.T, query, cursor string) (string, error) {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var filter models.BookFilter
+	scope, err := filters.Parse(&filter, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := newSQLRecorder()
+	db := dryRunDB(t, recorder)
+
+	var books []models.Book
+	if _, err := pagination.PaginateCursor(db, cursor, 10, false, scope, &books); err != nil {
+		return "", err
+	}
+
+	return strings.Join(recorder.statements, "\n"), nil
+}
+
+// testCursor builds a cursor like PaginateCursor does, the format is private to the pagination package
+func testCursor(json string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(json))
+}
+
+func TestCursorPaginationFirstPage(t *testing.T) {
+	sql, err := cursorPageSQL(t, "sort=-price", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sql, `ORDER BY "price" DESC,"id" LIMIT 11`) || strings.Contains(sql, "OFFSET") || strings.Contains(sql, "count(") {
+		t.Fatalf("unexpected SQL %s", sql)
+	}
+}
+
+func TestCursorPaginationContinuesAfterTheCursor(t *testing.T) {
+	sql, err := cursorPageSQL(t, "sort=-price", testCursor(`{"s":"-price,id","d":"next","v":[100,7]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{`("price" < 100 OR ("price" = 100 AND "id" > 7))`, `ORDER BY "price" DESC,"id" LIMIT 11`}
+	if !strings.Contains(sql, expected[0]) || !strings.Contains(sql, expected[1]) {
+		t.Fatalf("expected %v in %s", expected, sql)
+	}
+
+	sql, err = cursorPageSQL(t, "sort=-price", testCursor(`{"s":"-price,id","d":"prev","v":[100,7]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []string{`("price" > 100 OR ("price" = 100 AND "id" < 7))`, `ORDER BY "price","id" DESC LIMIT 11`}
+	if !strings.Contains(sql, expected[0]) || !strings.Contains(sql, expected[1]) {
+		t.Fatalf("expected %v in %s", expected, sql)
+	}
+}
+
+func TestCursorPaginationRejectsInvalidCursors(t *testing.T) {
+	for _, cursor := range []string{
+		"not a cursor",
+		testCursor(`{"s":"title,id","d":"next","v":["Go",7]}`),
+		testCursor(`{"s":"-price,id","d":"next","v":["cheap",7]}`),
+		testCursor(`{"s":"-price,id","d":"sideways","v":[100,7]}`),
+	} {
+		if _, err := cursorPageSQL(t, "sort=-price", cursor); !errors.Is(err, pagination.ErrInvalidCursor) {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", cursor, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
 	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
 	"github.com/joho/godotenv"
 )
 
@@ -20,15 +21,33 @@ func DatabaseRefresh() {
 	initializers.ConnectDB()
 
 	// Drop all the tables
-	err = initializers.DB.Migrator().DropTable(models.User{}, models.Order{}, models.Book{}, models.Employee{})
+	err = initializers.DB.Migrator().DropTable(
+		"user_roles", "role_permissions", "order_books",
+		models.User{}, models.Order{}, models.Book{}, models.Employee{},
+		models.RefreshToken{}, models.RevokedToken{}, models.UserTokenCutoff{},
+		models.Role{}, models.Permission{}, models.PasswordReset{}, models.RecoveryCode{},
+		models.LoginAttempt{}, models.Invitation{}, models.Identity{}, models.APIKey{},
+		models.Session{}, models.AuditLog{}, models.StockMovement{},
+	)
 	if err != nil {
 		log.Fatal("Table dropping failed")
 	}
 
 	// Migrate again
-	err = initializers.DB.AutoMigrate(models.User{}, models.Order{}, models.Book{}, models.Employee{})
+	err = initializers.DB.AutoMigrate(
+		models.User{}, models.Order{}, models.Book{}, models.Employee{},
+		models.RefreshToken{}, models.RevokedToken{}, models.UserTokenCutoff{},
+		models.Role{}, models.Permission{}, models.PasswordReset{}, models.RecoveryCode{},
+		models.LoginAttempt{}, models.Invitation{}, models.Identity{}, models.APIKey{},
+		models.Session{}, models.AuditLog{}, models.StockMovement{},
+	)
 
 	if err != nil {
 		log.Fatal("Migration failed")
 	}
+
+	// Seed the permissions and default roles
+	if err = rbac.Seed(initializers.DB); err != nil {
+		log.Fatal("Seeding roles failed")
+	}
 }
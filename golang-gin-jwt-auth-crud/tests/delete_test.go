@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/audit"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/inventory"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// failQueries makes the queries of the callback processor on table fail until the end of the test
+func failQueries(t *testing.T, processor interface {
+	Register(string, func(*gorm.DB)) error
+	Remove(string) error
+}, table string) {
+	name := "tests:fail_" + table
+	err := processor.Register(name, func(db *gorm.DB) {
+		if db.Statement.Table == table {
+			db.AddError(errors.New("the test failed the query"))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { processor.Remove(name) })
+}
+
+// countAudit counts the audit entries of the action on the book
+func countAudit(t *testing.T, action string, bookID int) int64 {
+	var count int64
+	err := initializers.DB.Model(&models.AuditLog{}).
+		Where("action = ? AND resource_type = ? AND resource_id = ?", action, audit.ResourceBook, bookID).
+		Count(&count).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return count
+}
+
+func TestFailedDeleteLeavesNoAuditEntry(t *testing.T) {
+	r := newTestApp(t)
+	createTestUser(t, "admin@example.com", rbac.RoleAdmin)
+	accessToken, _ := loginTestUser(t, r, "admin@example.com")
+
+	book := models.Book{Title: "Go", Price: 100, Category: "IT"}
+	if err := initializers.DB.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	failQueries(t, initializers.DB.Callback().Delete().Before("gorm:delete"), "books")
+
+	w := performRequest(r, http.MethodDelete, fmt.Sprintf("/api/books/%d", book.ID), nil, bearer(accessToken))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if count := countAudit(t, audit.ActionDelete, book.ID); count != 0 {
+		t.Fatalf("the failed delete left %d audit entries", count)
+	}
+}
+
+func TestFailedAuditEntryRollsTheDeleteBack(t *testing.T) {
+	r := newTestApp(t)
+	createTestUser(t, "admin@example.com", rbac.RoleAdmin)
+	accessToken, _ := loginTestUser(t, r, "admin@example.com")
+
+	book := models.Book{Title: "Go", Price: 100, Category: "IT"}
+	if err := initializers.DB.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	failQueries(t, initializers.DB.Callback().Create().Before("gorm:create"), "audit_logs")
+
+	w := performRequest(r, http.MethodDelete, fmt.Sprintf("/api/books/%d", book.ID), nil, bearer(accessToken))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := initializers.DB.First(&models.Book{}, book.ID).Error; err != nil {
+		t.Fatalf("the book was deleted without its audit entry: %v", err)
+	}
+}
+
+// createStockedBook creates a book with an initial stock through the API, which writes its opening entry
+func createStockedBook(t *testing.T, r http.Handler, accessToken string) models.Book {
+	w := performRequest(r, http.MethodPost, "/api/books/create", gin.H{"title": "Go", "price": 100, "category": "IT", "qty": 3}, bearer(accessToken))
+	if w.Code != http.StatusOK {
+		t.Fatalf("the book could not be created, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var book models.Book
+	if err := initializers.DB.First(&book, "title = ?", "Go").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return book
+}
+
+// countMovements counts the stock movements of the book
+func countMovements(bookID int) int64 {
+	var count int64
+	initializers.DB.Model(&models.StockMovement{}).Where("book_id = ?", bookID).Count(&count)
+	return count
+}
+
+func TestPermanentDeleteKeepsTheStockLedger(t *testing.T) {
+	r := newTestApp(t)
+	createTestUser(t, "admin@example.com", rbac.RoleAdmin)
+	accessToken, _ := loginTestUser(t, r, "admin@example.com")
+	book := createStockedBook(t, r, accessToken)
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		return inventory.Move(tx, &models.StockMovement{BookID: uint(book.ID), Type: models.StockReceipt, Quantity: 2, Reason: "Delivery"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := performRequest(r, http.MethodDelete, fmt.Sprintf("/api/books/delete-permanent/%d", book.ID), nil, bearer(accessToken))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if count := countMovements(book.ID); count != 2 {
+		t.Fatalf("expected the movements to be kept, %d left", count)
+	}
+}
+
+func TestPermanentDeleteRemovesTheOpeningEntry(t *testing.T) {
+	r := newTestApp(t)
+	createTestUser(t, "admin@example.com", rbac.RoleAdmin)
+	accessToken, _ := loginTestUser(t, r, "admin@example.com")
+	book := createStockedBook(t, r, accessToken)
+
+	w := performRequest(r, http.MethodDelete, fmt.Sprintf("/api/books/delete-permanent/%d", book.ID), nil, bearer(accessToken))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := initializers.DB.Unscoped().First(&models.Book{}, book.ID).Error; err == nil {
+		t.Fatal("the book was kept")
+	}
+
+	if count := countMovements(book.ID); count != 0 {
+		t.Fatalf("the opening entry was kept, %d movements left", count)
+	}
+}
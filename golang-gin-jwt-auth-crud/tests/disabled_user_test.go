@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDisabledUserIsLoggedOut(t *testing.T) {
+	r := newTestApp(t)
+	createTestUser(t, "admin@example.com", rbac.RoleAdmin)
+	jane := createTestUser(t, "jane@example.com")
+	adminToken, _ := loginTestUser(t, r, "admin@example.com")
+	accessToken, refreshToken := loginTestUser(t, r, "jane@example.com")
+
+	if w := performRequest(r, http.MethodPost, fmt.Sprintf("/api/users/%d/disable", jane.ID), nil, bearer(adminToken)); w.Code != http.StatusOK {
+		t.Fatalf("disabling the user failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	login := gin.H{"email": "jane@example.com", "password": testPassword}
+	if w := performRequest(r, http.MethodPost, "/api/login", login, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the login to be rejected, got %d", w.Code)
+	}
+
+	if w := performRequest(r, http.MethodGet, "/api/users/me", nil, bearer(accessToken)); w.Code == http.StatusOK {
+		t.Fatal("the access token still works")
+	}
+
+	if w := performRequest(r, http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": refreshToken}, nil); w.Code == http.StatusOK {
+		t.Fatal("the refresh token still works")
+	}
+}
+
+func TestDisabledUserCannotRefresh(t *testing.T) {
+	r := newTestApp(t)
+	jane := createTestUser(t, "jane@example.com")
+	accessToken, refreshToken := loginTestUser(t, r, "jane@example.com")
+
+	// Disabled without revoking the sessions
+	if err := initializers.DB.Model(&jane).Update("status", models.UserStatusDisabled).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if w := performRequest(r, http.MethodGet, "/api/users/me", nil, bearer(accessToken)); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the access token to be rejected, got %d", w.Code)
+	}
+
+	if w := performRequest(r, http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": refreshToken}, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the refresh to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+}
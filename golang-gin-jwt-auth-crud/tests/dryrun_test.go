@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder keeps the SQL of a dry run
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func newSQLRecorder() *sqlRecorder {
+	return &sqlRecorder{Interface: logger.Discard}
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// dryRunDB builds SQL without a database, log may be nil for the default logger. Writes skip the
+// default transaction, which would need a connection.
+func dryRunDB(t *testing.T, log logger.Interface) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 log,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+// checkIfMatch runs helpers.CheckIfMatch for version 3 with the If-Match header and returns the result and status
+func checkIfMatch(ifMatch string) (bool, int) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/books/update/1", nil)
+	if ifMatch != "" {
+		c.Request.Header.Set("If-Match", ifMatch)
+	}
+
+	ok := helpers.CheckIfMatch(c, 3)
+	return ok, w.Code
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	cases := []struct {
+		ifMatch string
+		ok      bool
+		status  int
+	}{
+		{ifMatch: `"3"`, ok: true},
+		{ifMatch: `"2", "3"`, ok: true},
+		{ifMatch: `*`, ok: true},
+		{ifMatch: `"2"`, status: http.StatusPreconditionFailed},
+		{ifMatch: `W/"3"`, status: http.StatusPreconditionFailed},
+		{ifMatch: "", ok: true},
+	}
+
+	for _, tc := range cases {
+		ok, status := checkIfMatch(tc.ifMatch)
+		if ok != tc.ok || (!ok && status != tc.status) {
+			t.Errorf("If-Match %q: got %v and %d, expected %v and %d", tc.ifMatch, ok, status, tc.ok, tc.status)
+		}
+	}
+}
+
+func TestCheckIfMatchCanBeRequired(t *testing.T) {
+	t.Setenv("REQUIRE_IF_MATCH", "true")
+
+	if ok, status := checkIfMatch(""); ok || status != http.StatusPreconditionRequired {
+		t.Fatalf("an update without If-Match got %v and %d, expected 428", ok, status)
+	}
+
+	if ok, _ := checkIfMatch(`"3"`); !ok {
+		t.Fatal("an update with the current ETag was rejected")
+	}
+}
+
+func TestOrderETagRoundTrip(t *testing.T) {
+	r := newTestApp(t)
+	createTestUser(t, "admin@example.com", rbac.RoleAdmin)
+	accessToken, _ := loginTestUser(t, r, "admin@example.com")
+
+	employee := models.Employee{Name: "John", Email: "john@example.com", BirthDate: "1990-01-31"}
+	if err := initializers.DB.Create(&employee).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	book := models.Book{Title: "Go", Price: 100, Category: "IT", Qty: 5}
+	if err := initializers.DB.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	order := gin.H{"employee_id": employee.ID, "order_date": "2024-01-31", "book_id": []int{book.ID}}
+	w := performRequest(r, http.MethodPost, "/api/orders/create", order, bearer(accessToken))
+	if w.Code != http.StatusOK {
+		t.Fatalf("the order could not be created, got %d: %s", w.Code, w.Body.String())
+	}
+	created, _ := decodeBody(t, w)["order"].(map[string]interface{})
+	path := fmt.Sprintf("/api/orders/%v", created["ID"])
+	updatePath := fmt.Sprintf("/api/orders/update/%v", created["ID"])
+
+	w = performRequest(r, http.MethodGet, path, nil, bearer(accessToken))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected the order with an ETag, got %d and %q: %s", w.Code, etag, w.Body.String())
+	}
+
+	header := bearer(accessToken)
+	header.Set("If-Match", etag)
+	order["order_date"] = "2024-02-01"
+	w = performRequest(r, http.MethodPut, updatePath, order, header)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("the update with the current ETag got %d and ETag %q: %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+	}
+
+	// The ETag read before the update is stale now
+	order["order_date"] = "2024-02-02"
+	if w = performRequest(r, http.MethodPut, updatePath, order, header); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a stale ETag, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/filters"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"gorm.io/gorm"
+)
+
+// bookListSQL returns the SQL of the book list for the query string
+func bookListSQL(t *testing.T, query string) (string, models.BookFilter, error) {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var filter models.BookFilter
+	scope, err := filters.Parse(&filter, values)
+	if err != nil {
+		return "", filter, err
+	}
+
+	db := dryRunDB(t, nil)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var books []models.Book
+		return tx.Scopes(scope).Find(&books)
+	})
+
+	return sql, filter, nil
+}
+
+func TestFiltersBuildConditions(t *testing.T) {
+	cases := map[string]string{
+		"price=5":                   `"price" = 5`,
+		"price[gte]=100":            `"price" >= 100`,
+		"price[ne]=3":               `"price" <> 3`,
+		"price_max=500":             `"price" <= 500`,
+		"price[between]=100,500":    `"price" BETWEEN 100 AND 500`,
+		"book_ids=1,2&book_ids=3":   `"id" IN (1,2,3)`,
+		"category[in]=Novel,Poetry": `"category" IN ('Novel','Poetry')`,
+		"title_like=50%25":          `"title" ILIKE '%50\%%'`,
+		"search=go":                 `("title" ILIKE '%go%' OR "category" ILIKE '%go%')`,
+	}
+
+	for query, expected := range cases {
+		sql, _, err := bookListSQL(t, query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+
+		if !strings.Contains(sql, expected) {
+			t.Errorf("%s: expected %s in %s", query, expected, sql)
+		}
+	}
+}
+
+func TestFiltersFillTheFilter(t *testing.T) {
+	_, filter, err := bookListSQL(t, "page=2&limit=20&price_min=10&book_ids=4,5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filter.Page != 2 || filter.Limit != 20 || filter.PriceMin == nil || *filter.PriceMin != 10 || len(filter.BookIDS) != 2 {
+		t.Fatalf("unexpected filter %+v", filter)
+	}
+}
+
+func TestFiltersSort(t *testing.T) {
+	sql, _, err := bookListSQL(t, "sort=-price,title")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sql, `ORDER BY "price" DESC,"title","id"`) {
+		t.Fatalf("unexpected order in %s", sql)
+	}
+
+	sql, _, err = bookListSQL(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sql, `ORDER BY "id"`) {
+		t.Fatalf("the list is not ordered by id: %s", sql)
+	}
+}
+
+func TestFiltersRejectInvalidParameters(t *testing.T) {
+	for _, query := range []string{
+		"author=Jane",
+		"price[like]=5",
+		"price=cheap",
+		"price[between]=5",
+		"sort=qty%3Bdrop table books",
+		"sort=deleted_at",
+		"title[regex]=x",
+		"created_at=yesterday",
+		"created_at[between]=2024-01-01,soon",
+	} {
+		if _, _, err := bookListSQL(t, query); err == nil {
+			t.Errorf("%s was accepted", query)
+		}
+	}
+}
+
+func TestFiltersParseDates(t *testing.T) {
+	sql, filter, err := bookListSQL(t, "created_at=2024-01-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filter.CreatedAt == nil || !filter.CreatedAt.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected created_at %v", filter.CreatedAt)
+	}
+
+	if !strings.Contains(sql, `"created_at" >= '2024-01-31 00:00:00'`) {
+		t.Fatalf("unexpected condition in %s", sql)
+	}
+
+	if _, _, err := bookListSQL(t, "created_at[lt]=2024-01-31T12:30:00Z"); err != nil {
+		t.Fatalf("an RFC 3339 time was rejected: %v", err)
+	}
+}
+
+func TestFiltersValidateDateColumns(t *testing.T) {
+	for query, valid := range map[string]bool{
+		"birth_date=1990-05-17":                     true,
+		"birth_date[between]=1990-01-01,1999-12-31": true,
+		"birth_date=17/05/1990":                     false,
+		"birth_date[gte]=1990-13-01":                false,
+		"birth_date[between]=1990-01-01,later":      false,
+	} {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var filter models.EmployeeFilter
+		if _, err := filters.Parse(&filter, values); (err == nil) != valid {
+			t.Errorf("%s: expected valid %v, got %v", query, valid, err)
+		}
+	}
+}
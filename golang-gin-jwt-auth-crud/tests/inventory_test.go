@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/inventory"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+)
+
+func TestSignedQuantity(t *testing.T) {
+	cases := []struct {
+		movementType string
+		quantity     int
+		expected     int
+	}{
+		{models.StockReceipt, 5, 5},
+		{models.StockReturn, 1, 1},
+		{models.StockSale, 2, -2},
+		{models.StockAdjustment, -3, -3},
+		{models.StockAdjustment, 4, 4},
+	}
+
+	for _, tc := range cases {
+		quantity, err := inventory.SignedQuantity(tc.movementType, tc.quantity)
+		if err != nil || quantity != tc.expected {
+			t.Errorf("%s of %d gave %d, %v", tc.movementType, tc.quantity, quantity, err)
+		}
+	}
+}
+
+func TestSignedQuantityRejectsInvalidMovements(t *testing.T) {
+	for _, tc := range []struct {
+		movementType string
+		quantity     int
+		err          error
+	}{
+		{models.StockReceipt, 0, inventory.ErrInvalidQuantity},
+		{models.StockReturn, -1, inventory.ErrInvalidQuantity},
+		{models.StockSale, -2, inventory.ErrInvalidQuantity},
+		{models.StockAdjustment, 0, inventory.ErrInvalidQuantity},
+		{"theft", 1, inventory.ErrUnknownType},
+	} {
+		if _, err := inventory.SignedQuantity(tc.movementType, tc.quantity); !errors.Is(err, tc.err) {
+			t.Errorf("%s of %d: expected %v, got %v", tc.movementType, tc.quantity, tc.err, err)
+		}
+	}
+}
+
+func TestMoveLocksTheBookAndWritesTheBalance(t *testing.T) {
+	// The book read by the dry run has no stock
+	recorder := newSQLRecorder()
+	db := dryRunDB(t, recorder)
+
+	movement := models.StockMovement{BookID: 7, Type: models.StockReceipt, Quantity: 3, Reason: "Delivery"}
+	if err := inventory.Move(db, &movement); err != nil {
+		t.Fatal(err)
+	}
+
+	if movement.BalanceAfter != 3 {
+		t.Fatalf("expected a balance of 3, got %d", movement.BalanceAfter)
+	}
+
+	sql := strings.Join(recorder.statements, "\n")
+	for _, expected := range []string{"FOR UPDATE", `UPDATE "books" SET "qty"=3`, `INSERT INTO "stock_movements"`} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("expected %s in %s", expected, sql)
+		}
+	}
+
+	if strings.Contains(sql, `"version"`) {
+		t.Errorf("a stock movement changed the version of the book: %s", sql)
+	}
+}
+
+func TestMoveRejectsNegativeStock(t *testing.T) {
+	// The book read by the dry run has no stock
+	recorder := newSQLRecorder()
+	db := dryRunDB(t, recorder)
+
+	movement := models.StockMovement{BookID: 7, Type: models.StockSale, Quantity: -1, Reason: "Order #1"}
+	err := inventory.Move(db, &movement)
+
+	var insufficient *inventory.InsufficientStockError
+	if !errors.As(err, &insufficient) || insufficient.Available != 0 || insufficient.Requested != 1 {
+		t.Fatalf("expected an InsufficientStockError, got %v", err)
+	}
+
+	if sql := strings.Join(recorder.statements, "\n"); strings.Contains(sql, "INSERT") || strings.Contains(sql, "UPDATE \"books\"") {
+		t.Fatalf("the rejected movement was written: %s", sql)
+	}
+}
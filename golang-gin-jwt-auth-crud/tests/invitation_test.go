@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+func TestInviteOnlySignupRejectsStrangers(t *testing.T) {
+	t.Setenv("SIGNUP_MODE", "invite")
+	r := newTestApp(t)
+
+	signup := gin.H{"name": "Jane", "email": "jane@example.com", "password": testPassword}
+	if w := performRequest(r, http.MethodPost, "/api/signup", signup, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected a signup without invitation to be rejected, got %d", w.Code)
+	}
+
+	signup["invitation_token"] = "not-an-invitation"
+	if w := performRequest(r, http.MethodPost, "/api/signup", signup, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid invitation to be rejected, got %d", w.Code)
+	}
+
+	var count int64
+	initializers.DB.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("the rejected signups created %d users", count)
+	}
+}
+
+func TestInvitationFixesTheEmailAndRole(t *testing.T) {
+	t.Setenv("SIGNUP_MODE", "invite")
+	r := newTestApp(t)
+	box := useMailbox(t)
+	createTestUser(t, "admin@example.com", rbac.RoleAdmin)
+	adminToken, _ := loginTestUser(t, r, "admin@example.com")
+
+	invite := gin.H{"email": "jane@example.com", "role": rbac.RoleClerk}
+	if w := performRequest(r, http.MethodPost, "/api/invitations/create", invite, bearer(adminToken)); w.Code != http.StatusOK {
+		t.Fatalf("the invitation failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	signup := gin.H{"name": "Jane", "password": testPassword, "invitation_token": box.mailedToken(t)}
+	if w := performRequest(r, http.MethodPost, "/api/signup", signup, nil); w.Code != http.StatusOK {
+		t.Fatalf("the invited signup failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	var user models.User
+	if err := initializers.DB.Preload("Roles").First(&user, "email = ?", "jane@example.com").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(user.Roles) != 1 || user.Roles[0].Name != rbac.RoleClerk || user.EmailVerifiedAt == nil {
+		t.Fatalf("unexpected invited user %+v", user)
+	}
+
+	// An invitation can only be used once
+	if w := performRequest(r, http.MethodPost, "/api/signup", signup, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected the used invitation to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+)
+
+// writeEd25519Key writes a new key pair as PEM files and returns their paths
+func writeEd25519Key(t *testing.T, dir, name string) (string, string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privatePath := filepath.Join(dir, name+".pem")
+	publicPath := filepath.Join(dir, name+".pub.pem")
+
+	if err := os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return privatePath, publicPath
+}
+
+func TestEdDSAKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldPrivate, oldPublic := writeEd25519Key(t, dir, "old")
+	newPrivate, _ := writeEd25519Key(t, dir, "new")
+
+	previous := tokens.Keys
+	defer func() { tokens.Keys = previous }()
+
+	t.Setenv("JWT_ALG", "EdDSA")
+
+	// Issue a token with the old key
+	t.Setenv("JWT_PRIVATE_KEY_FILE", oldPrivate)
+	t.Setenv("JWT_KEY_ID", "old")
+
+	keys, err := tokens.LoadKeySet()
+	if err != nil {
+		t.Fatalf("LoadKeySet returned error: %v", err)
+	}
+	tokens.Keys = keys
+
+	oldToken, _, err := tokens.GenerateAccessToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate to the new key while still accepting the old one
+	t.Setenv("JWT_PRIVATE_KEY_FILE", newPrivate)
+	t.Setenv("JWT_KEY_ID", "new")
+	t.Setenv("JWT_PUBLIC_KEY_FILES", "old="+oldPublic)
+
+	if tokens.Keys, err = tokens.LoadKeySet(); err != nil {
+		t.Fatalf("LoadKeySet returned error: %v", err)
+	}
+
+	if _, err := tokens.ParseAccessToken(oldToken); err != nil {
+		t.Fatalf("token signed with the previous key was rejected: %v", err)
+	}
+
+	newToken, _, err := tokens.GenerateAccessToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tokens.ParseAccessToken(newToken); err != nil {
+		t.Fatalf("token signed with the current key was rejected: %v", err)
+	}
+
+	jwks := tokens.Keys.JWKS()["keys"].([]map[string]string)
+	if len(jwks) != 2 {
+		t.Fatalf("expected 2 published keys, got %d", len(jwks))
+	}
+
+	for _, jwk := range jwks {
+		if jwk["kty"] != "OKP" || jwk["alg"] != "EdDSA" || jwk["x"] == "" {
+			t.Fatalf("unexpected jwk: %v", jwk)
+		}
+	}
+
+	// Once the old key is dropped its tokens are no longer accepted
+	t.Setenv("JWT_PUBLIC_KEY_FILES", "")
+	if tokens.Keys, err = tokens.LoadKeySet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tokens.ParseAccessToken(oldToken); err == nil {
+		t.Fatal("expected the token of the removed key to be rejected")
+	}
+}
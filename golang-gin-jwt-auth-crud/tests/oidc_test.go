@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/oidc"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/totp"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockOIDCServer is a minimal identity provider issuing id tokens for one authorization code
+type mockOIDCServer struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	code     string
+	verifier string
+	nonce    string
+	// claims overrides the claims of the issued id token
+	claims jwt.MapClaims
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockOIDCServer{key: key, code: "the-code"}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "mock",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != m.code || r.PostFormValue("code_verifier") != m.verifier {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":            m.URL,
+			"aud":            "client",
+			"sub":            "user-1",
+			"email":          "jane@example.com",
+			"email_verified": true,
+			"name":           "Jane",
+			"nonce":          m.nonce,
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		}
+		for name, value := range m.claims {
+			claims[name] = value
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "mock"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "id_token": idToken})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+func (m *mockOIDCServer) provider() *oidc.Provider {
+	return &oidc.Provider{
+		Name:        "mock",
+		Issuer:      m.URL,
+		ClientID:    "client",
+		RedirectURL: "http://localhost:3000/api/oidc/mock/callback",
+		Scopes:      []string{"openid", "email"},
+	}
+}
+
+func TestOIDCAuthCodeURLUsesPKCE(t *testing.T) {
+	m := newMockOIDCServer(t)
+
+	authURL, err := m.provider().AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := parsed.Query()
+	if parsed.Path != "/authorize" || query.Get("state") != "state" || query.Get("nonce") != "nonce" {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") != oidc.Challenge("verifier") {
+		t.Fatalf("the authorization URL has no S256 code challenge: %s", authURL)
+	}
+}
+
+func TestOIDCExchangeVerifiesIDToken(t *testing.T) {
+	m := newMockOIDCServer(t)
+	m.verifier, m.nonce = "verifier", "nonce"
+
+	identity, err := m.provider().Exchange(context.Background(), m.code, "verifier", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if identity.Subject != "user-1" || identity.Email != "jane@example.com" || !identity.EmailVerified || identity.Name != "Jane" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+}
+
+func TestOIDCExchangeRejectsInvalidIDTokens(t *testing.T) {
+	cases := map[string]jwt.MapClaims{
+		"wrong audience": {"aud": "another-client"},
+		"wrong issuer":   {"iss": "https://evil.example.com"},
+		"expired":        {"exp": time.Now().Add(-time.Hour).Unix()},
+		"wrong nonce":    {"nonce": "replayed"},
+	}
+
+	for name, claims := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := newMockOIDCServer(t)
+			m.verifier, m.nonce, m.claims = "verifier", "nonce", claims
+
+			_, err := m.provider().Exchange(context.Background(), m.code, "verifier", "nonce")
+			if !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeRequiresTheVerifier(t *testing.T) {
+	m := newMockOIDCServer(t)
+	m.verifier, m.nonce = "verifier", "nonce"
+
+	if _, err := m.provider().Exchange(context.Background(), m.code, "stolen-code-without-verifier", "nonce"); err == nil {
+		t.Fatal("the code was exchanged without the PKCE verifier")
+	}
+}
+
+func TestOIDCCallbackRequiresTheSecondFactor(t *testing.T) {
+	r := newTestApp(t)
+	m := newMockOIDCServer(t)
+
+	previous := oidc.Providers
+	oidc.Providers = map[string]*oidc.Provider{"mock": m.provider()}
+	t.Cleanup(func() { oidc.Providers = previous })
+
+	// The identity of the mock provider is linked to this user by the verified email
+	user := createTestUser(t, "jane@example.com")
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enabledAt := time.Now()
+	initializers.DB.Model(&user).Updates(models.User{TOTPSecret: secret, TOTPEnabledAt: &enabledAt})
+
+	// The login stores the state, the nonce and the verifier in a cookie, the mock provider needs them
+	w := performRequest(r, http.MethodGet, "/api/oidc/mock/login", nil, nil)
+	var stateCookie *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "OIDCState" {
+			stateCookie = cookie
+		}
+	}
+
+	if w.Code != http.StatusFound || stateCookie == nil {
+		t.Fatalf("the login did not redirect to the provider: %d %s", w.Code, w.Body.String())
+	}
+
+	claims, err := tokens.ParseTypedToken(tokens.TypeOIDCState, stateCookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.verifier, m.nonce = claims["verifier"].(string), claims["nonce"].(string)
+
+	callback := "/api/oidc/mock/callback?code=" + m.code + "&state=" + url.QueryEscape(claims["state"].(string))
+	w = performRequest(r, http.MethodGet, callback, nil, http.Header{"Cookie": {stateCookie.String()}})
+	body := decodeBody(t, w)
+
+	if w.Code != http.StatusOK || body["mfa_required"] != true || body["mfa_token"] == nil {
+		t.Fatalf("expected the second factor to be required, got %d %s", w.Code, w.Body.String())
+	}
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "Authorization" || cookie.Name == "RefreshToken" {
+			t.Fatalf("the callback logged the user in without the second factor: %s", cookie.Name)
+		}
+	}
+
+	// The pending token and a code finish the login
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = performRequest(r, http.MethodPost, "/api/login/2fa", gin.H{
+		"mfa_token":     body["mfa_token"],
+		"code":          code,
+		"token_in_body": true,
+	}, nil)
+	if w.Code != http.StatusOK || decodeBody(t, w)["access_token"] == nil {
+		t.Fatalf("the second factor was not accepted: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/pagination"
+	"gorm.io/gorm"
+)
+
+func TestParsePageParamsDefaults(t *testing.T) {
+	params, invalid := pagination.ParsePageParams("", "", "limit", 10)
+	if invalid != nil || params.Page != 1 || params.Limit != 10 {
+		t.Fatalf("unexpected defaults %+v %v", params, invalid)
+	}
+
+	params, invalid = pagination.ParsePageParams("3", "25", "limit", 10)
+	if invalid != nil || params.Page != 3 || params.Limit != 25 {
+		t.Fatalf("unexpected params %+v %v", params, invalid)
+	}
+}
+
+func TestParsePageParamsRejectsBadValues(t *testing.T) {
+	t.Setenv("PAGINATION_MAX_LIMIT", "50")
+
+	cases := []struct {
+		page, limit, field string
+	}{
+		{page: "0", field: "page"},
+		{page: "-2", field: "page"},
+		{page: "first", field: "page"},
+		{limit: "0", field: "perPage"},
+		{limit: "ten", field: "perPage"},
+		{limit: "51", field: "perPage"},
+	}
+
+	for _, tc := range cases {
+		_, invalid := pagination.ParsePageParams(tc.page, tc.limit, "perPage", 10)
+		if _, ok := invalid[tc.field]; !ok {
+			t.Errorf("page %q and perPage %q: expected a message for %s, got %v", tc.page, tc.limit, tc.field, invalid)
+		}
+	}
+
+	// A default above the maximum is lowered instead of rejected
+	t.Setenv("PAGINATION_MAX_LIMIT", "5")
+	if params, invalid := pagination.ParsePageParams("", "", "perPage", 10); invalid != nil || params.Limit != 5 {
+		t.Fatalf("unexpected params %+v %v", params, invalid)
+	}
+}
+
+func TestPaginateReturnsErrors(t *testing.T) {
+	db := dryRunDB(t, nil)
+	var books []models.Book
+
+	if _, err := pagination.Paginate(db, 1, 0, nil, &books); !errors.Is(err, pagination.ErrInvalidLimit) {
+		t.Fatalf("a zero limit gave %v", err)
+	}
+
+	failure := errors.New("connection lost")
+	_, err := pagination.Paginate(db, 1, 10, func(query *gorm.DB) *gorm.DB {
+		query.AddError(failure)
+		return query
+	}, &books)
+
+	if !errors.Is(err, failure) {
+		t.Fatalf("the query error was swallowed, got %v", err)
+	}
+}
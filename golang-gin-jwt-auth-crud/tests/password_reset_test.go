@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// newPassword follows the password policy and differs from testPassword
+const newPassword = "Other-Pass-456"
+
+// forgotPassword asks for a reset link and returns its token
+func forgotPassword(t *testing.T, r http.Handler, box *mailbox, email string) string {
+	if w := performRequest(r, http.MethodPost, "/api/password/forgot", gin.H{"email": email}, nil); w.Code != http.StatusOK {
+		t.Fatalf("the password reset request failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	return box.mailedToken(t)
+}
+
+func TestPasswordResetIsSingleUseAndRevokesTheSessions(t *testing.T) {
+	r := newTestApp(t)
+	box := useMailbox(t)
+	createTestUser(t, "jane@example.com")
+	accessToken, refreshToken := loginTestUser(t, r, "jane@example.com")
+
+	token := forgotPassword(t, r, box, "jane@example.com")
+	reset := gin.H{"token": token, "password": newPassword}
+
+	if w := performRequest(r, http.MethodPost, "/api/password/reset", reset, nil); w.Code != http.StatusOK {
+		t.Fatalf("the reset failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := performRequest(r, http.MethodPost, "/api/password/reset", reset, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected the used token to be rejected, got %d", w.Code)
+	}
+
+	// The sessions from before the reset are logged out
+	if w := performRequest(r, http.MethodGet, "/api/users/me", nil, bearer(accessToken)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the old access token to be rejected, got %d", w.Code)
+	}
+
+	if w := performRequest(r, http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": refreshToken}, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the old refresh token to be rejected, got %d", w.Code)
+	}
+
+	login := gin.H{"email": "jane@example.com", "password": newPassword}
+	if w := performRequest(r, http.MethodPost, "/api/login", login, nil); w.Code != http.StatusOK {
+		t.Fatalf("the login with the new password failed with %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestExpiredPasswordResetIsRejected(t *testing.T) {
+	r := newTestApp(t)
+	box := useMailbox(t)
+	createTestUser(t, "jane@example.com")
+
+	token := forgotPassword(t, r, box, "jane@example.com")
+	if err := initializers.DB.Model(&models.PasswordReset{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if w := performRequest(r, http.MethodPost, "/api/password/reset", gin.H{"token": token, "password": newPassword}, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected the expired token to be rejected, got %d", w.Code)
+	}
+
+	// The password was not changed
+	loginTestUser(t, r, "jane@example.com")
+}
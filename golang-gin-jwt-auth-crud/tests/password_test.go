@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/validations"
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidatePassword(t *testing.T) {
+	t.Setenv("PASSWORD_MIN_LENGTH", "10")
+	t.Setenv("PASSWORD_REQUIRE_SYMBOL", "true")
+
+	cases := map[string]int{
+		"Sup3r-Secret!": 0,
+		"123456":        4, // too short, no upper, no lower, no symbol
+		"alllowercase1": 2, // no upper, no symbol
+		"NoDigitsHere!": 1,
+	}
+
+	for password, expected := range cases {
+		if errs := validations.ValidatePassword(password); len(errs) != expected {
+			t.Errorf("%q: expected %d broken rules, got %v", password, expected, errs)
+		}
+	}
+}
+
+func TestSignupFollowsThePasswordPolicy(t *testing.T) {
+	t.Setenv("SIGNUP_MODE", "invite")
+	r := newTestApp(t)
+	box := useMailbox(t)
+	createTestUser(t, "admin@example.com", rbac.RoleAdmin)
+	adminToken, _ := loginTestUser(t, r, "admin@example.com")
+
+	invite := gin.H{"email": "jane@example.com", "role": rbac.RoleClerk}
+	if w := performRequest(r, http.MethodPost, "/api/invitations/create", invite, bearer(adminToken)); w.Code != http.StatusOK {
+		t.Fatalf("the invitation failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	// Long enough for the old minimum of 6 characters but not for the policy
+	signup := gin.H{"name": "Jane", "password": "secret1", "invitation_token": box.mailedToken(t)}
+	w := performRequest(r, http.MethodPost, "/api/signup", signup, nil)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if errs, _ := decodeBody(t, w)["validations"].(map[string]interface{}); errs["Password"] == nil {
+		t.Fatalf("expected the broken password rules, got %s", w.Body.String())
+	}
+
+	if err := initializers.DB.First(&models.User{}, "email = ?", "jane@example.com").Error; err == nil {
+		t.Fatal("the user was created with a password that breaks the policy")
+	}
+
+	// The rejected signup did not use up the invitation
+	signup["password"] = testPassword
+	if w := performRequest(r, http.MethodPost, "/api/signup", signup, nil); w.Code != http.StatusOK {
+		t.Fatalf("the signup with a valid password failed with %d: %s", w.Code, w.Body.String())
+	}
+}
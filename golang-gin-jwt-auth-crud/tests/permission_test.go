@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/api/middleware"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+// requirePermission sends a request as authUser, nil for an anonymous one, through the
+// RequirePermission middleware and returns the status and whether the handler ran
+func requirePermission(authUser *middleware.AuthUser, permission string) (int, bool) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	handled := false
+	r.GET("/", func(c *gin.Context) {
+		if authUser != nil {
+			c.Set("authUser", *authUser)
+		}
+	}, middleware.RequirePermission(permission), func(c *gin.Context) {
+		handled = true
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	return w.Code, handled
+}
+
+func TestRequirePermission(t *testing.T) {
+	cases := []struct {
+		name     string
+		authUser *middleware.AuthUser
+		status   int
+		handled  bool
+	}{
+		{"anonymous", nil, http.StatusUnauthorized, false},
+		{"without the permission", &middleware.AuthUser{ID: 1, Permissions: []string{rbac.BooksRead}}, http.StatusForbidden, false},
+		{"with the permission", &middleware.AuthUser{ID: 1, Permissions: []string{rbac.BooksRead, rbac.BooksDelete}}, http.StatusOK, true},
+	}
+
+	for _, tc := range cases {
+		status, handled := requirePermission(tc.authUser, rbac.BooksDelete)
+		if status != tc.status || handled != tc.handled {
+			t.Errorf("%s: expected %d and handled %v, got %d and %v", tc.name, tc.status, tc.handled, status, handled)
+		}
+	}
+}
+
+func TestRoutesRequireTheirPermission(t *testing.T) {
+	r := newTestApp(t)
+	createTestUser(t, "clerk@example.com", rbac.RoleClerk)
+	accessToken, _ := loginTestUser(t, r, "clerk@example.com")
+
+	book := models.Book{Title: "Go", Price: 100, Category: "IT"}
+	if err := initializers.DB.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// The clerk can read the books but not delete them
+	if w := performRequest(r, http.MethodGet, fmt.Sprintf("/api/books/%d", book.ID), nil, bearer(accessToken)); w.Code != http.StatusOK {
+		t.Fatalf("expected the clerk to read the book, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := performRequest(r, http.MethodDelete, fmt.Sprintf("/api/books/%d", book.ID), nil, bearer(accessToken)); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := initializers.DB.First(&models.Book{}, book.ID).Error; err != nil {
+		t.Fatalf("the book was deleted without the permission: %v", err)
+	}
+}
+
+// authorizeOwner runs helpers.AuthorizeOwnerOrPermission as authUser for a record of user 1
+func authorizeOwner(authUser middleware.AuthUser) (bool, int) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/users/update/1", nil)
+	c.Set("authUser", authUser)
+
+	ok := helpers.AuthorizeOwnerOrPermission(c, 1, rbac.UsersUpdate)
+	return ok, w.Code
+}
+
+func TestAuthorizeOwnerOrPermission(t *testing.T) {
+	cases := []struct {
+		name     string
+		authUser middleware.AuthUser
+		ok       bool
+	}{
+		{"owner", middleware.AuthUser{ID: 1}, true},
+		{"other user", middleware.AuthUser{ID: 2}, false},
+		{"other user with the permission", middleware.AuthUser{ID: 2, Permissions: []string{rbac.UsersUpdate}}, true},
+		{"API key of the owner", middleware.AuthUser{ID: 1, APIKeyID: 7}, false},
+		{"API key of the owner with the permission", middleware.AuthUser{ID: 1, APIKeyID: 7, Permissions: []string{rbac.UsersUpdate}}, true},
+	}
+
+	for _, tc := range cases {
+		ok, status := authorizeOwner(tc.authUser)
+		if ok != tc.ok {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.ok, ok)
+		}
+
+		if !ok && status != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", tc.name, status)
+		}
+	}
+}
+
+func TestUsersCanOnlyUpdateTheirOwnAccount(t *testing.T) {
+	r := newTestApp(t)
+	jane := createTestUser(t, "jane@example.com", rbac.RoleClerk)
+	john := createTestUser(t, "john@example.com", rbac.RoleClerk)
+	accessToken, _ := loginTestUser(t, r, "jane@example.com")
+
+	rename := gin.H{"name": "Renamed", "email": "john@example.com"}
+	if w := performRequest(r, http.MethodPut, fmt.Sprintf("/api/users/update/%d", john.ID), rename, bearer(accessToken)); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for the account of another user, got %d: %s", w.Code, w.Body.String())
+	}
+
+	rename = gin.H{"name": "Renamed", "email": "jane@example.com"}
+	if w := performRequest(r, http.MethodPut, fmt.Sprintf("/api/users/update/%d", jane.ID), rename, bearer(accessToken)); w.Code != http.StatusOK {
+		t.Fatalf("the update of the own account failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	initializers.DB.First(&john, john.ID)
+	if john.Name == "Renamed" {
+		t.Fatal("the account of another user was renamed")
+	}
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/throttle"
+)
+
+func TestLimiterLocksOutWithExponentialBackoff(t *testing.T) {
+	limiter := &throttle.Limiter{
+		Store:       throttle.NewMemoryStore(),
+		Prefix:      "account:",
+		MaxAttempts: 3,
+		BaseLockout: time.Minute,
+		MaxLockout:  5 * time.Minute,
+		Window:      time.Hour,
+	}
+
+	key := "john@doe.com"
+	expected := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute}
+
+	for i, want := range expected {
+		lockout, err := limiter.Fail(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if lockout != want {
+			t.Fatalf("failure %d: expected lockout %v, got %v", i+1, want, lockout)
+		}
+	}
+
+	if retryAfter, _ := limiter.RetryAfter(key); retryAfter <= 4*time.Minute {
+		t.Fatalf("expected the key to be locked out, retry after %v", retryAfter)
+	}
+
+	if err := limiter.Reset(key); err != nil {
+		t.Fatal(err)
+	}
+
+	if retryAfter, _ := limiter.RetryAfter(key); retryAfter != 0 {
+		t.Fatalf("expected the key to be unlocked, retry after %v", retryAfter)
+	}
+}
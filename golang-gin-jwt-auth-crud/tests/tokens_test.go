@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	t.Setenv("SECRET", "test-secret")
+	t.Setenv("ACCESS_TOKEN_TTL", "5m")
+
+	tokenString, expiresAt, err := tokens.GenerateAccessToken(42)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken returned error: %v", err)
+	}
+
+	if time.Until(expiresAt) > 5*time.Minute {
+		t.Fatalf("access token expires too late: %v", expiresAt)
+	}
+
+	claims, err := tokens.ParseAccessToken(tokenString)
+	if err != nil {
+		t.Fatalf("ParseAccessToken returned error: %v", err)
+	}
+
+	if claims["sub"] != float64(42) {
+		t.Fatalf("unexpected sub claim: %v", claims["sub"])
+	}
+}
+
+func TestParseAccessTokenRejectsOtherTokens(t *testing.T) {
+	t.Setenv("SECRET", "test-secret")
+
+	sign := func(claims jwt.MapClaims) string {
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tokenString
+	}
+
+	cases := map[string]string{
+		"expired":    sign(jwt.MapClaims{"sub": 1, "typ": tokens.TypeAccess, "exp": time.Now().Add(-time.Minute).Unix()}),
+		"no expiry":  sign(jwt.MapClaims{"sub": 1, "typ": tokens.TypeAccess}),
+		"wrong type": sign(jwt.MapClaims{"sub": 1, "typ": "refresh", "exp": time.Now().Add(time.Minute).Unix()}),
+		"garbage":    "not-a-token",
+	}
+
+	for name, tokenString := range cases {
+		if _, err := tokens.ParseAccessToken(tokenString); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+}
+
+func TestRevokedAccessTokens(t *testing.T) {
+	t.Setenv("SECRET", "test-secret")
+
+	previous := tokens.Revocations
+	tokens.Revocations = tokens.NewMemoryRevocationStore()
+	defer func() { tokens.Revocations = previous }()
+
+	parse := func() jwt.MapClaims {
+		tokenString, _, err := tokens.GenerateAccessToken(7)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims, err := tokens.ParseAccessToken(tokenString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claims
+	}
+
+	first, second := parse(), parse()
+	if err := tokens.RevokeToken(first); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, _ := tokens.IsRevoked(first); !revoked {
+		t.Fatal("expected the logged out token to be revoked")
+	}
+
+	if revoked, _ := tokens.IsRevoked(second); revoked {
+		t.Fatal("expected the other token to stay valid")
+	}
+
+	// Log out all sessions revokes the tokens issued before it, but not the ones issued after
+	time.Sleep(5 * time.Millisecond)
+	if err := tokens.Revocations.RevokeAllForUser(7, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if revoked, _ := tokens.IsRevoked(second); !revoked {
+		t.Fatal("expected the token issued before the cutoff to be revoked")
+	}
+
+	if revoked, _ := tokens.IsRevoked(parse()); revoked {
+		t.Fatal("expected a token issued after the cutoff to be valid")
+	}
+}
+
+func TestSessionAccessTokenCarriesTheSession(t *testing.T) {
+	t.Setenv("SECRET", "test-secret")
+
+	tokenString, _, err := tokens.GenerateSessionAccessToken(3, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := tokens.ParseAccessToken(tokenString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tokens.UserID(claims) != 3 || tokens.SessionID(claims) != 9 {
+		t.Fatalf("unexpected user %d and session %d", tokens.UserID(claims), tokens.SessionID(claims))
+	}
+
+	plain, _, err := tokens.GenerateAccessToken(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err = tokens.ParseAccessToken(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tokens.SessionID(claims) != 0 {
+		t.Fatalf("a token without session has session %d", tokens.SessionID(claims))
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/totp"
+)
+
+func TestTOTPCodeMatchesRFC6238Vectors(t *testing.T) {
+	// The SHA1 secret of RFC 6238 appendix B
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	// RFC 6238 uses 8 digits, the last 6 digits are the 6 digit code
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := totp.Code(secret, totp.Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != expected[2:] {
+			t.Errorf("time %d: expected %s, got %s", unix, expected[2:], code)
+		}
+	}
+}
+
+func TestTOTPValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	previous, _ := totp.Code(secret, totp.Step(now)-1)
+	tooOld, _ := totp.Code(secret, totp.Step(now)-3)
+
+	if step, ok := totp.Validate(secret, previous, now); !ok || step != totp.Step(now)-1 {
+		t.Fatal("expected the code of the previous period to be accepted")
+	}
+
+	if _, ok := totp.Validate(secret, tooOld, now); ok {
+		t.Fatal("expected an old code to be rejected")
+	}
+
+	uri := totp.URI("Backend Gin", "john@doe.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Backend%20Gin:john@doe.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRestoreConflictsWithReusedValues(t *testing.T) {
+	r := newTestApp(t)
+	createTestUser(t, "admin@example.com", rbac.RoleAdmin)
+	accessToken, _ := loginTestUser(t, r, "admin@example.com")
+
+	// The address of a deleted user is free for a new one
+	jane := createTestUser(t, "jane@example.com", rbac.RoleClerk)
+	if err := initializers.DB.Delete(&jane).Error; err != nil {
+		t.Fatal(err)
+	}
+	createTestUser(t, "jane@example.com", rbac.RoleClerk)
+
+	employee := models.Employee{Name: "John", Email: "john@example.com", BirthDate: "1990-01-31"}
+	if err := initializers.DB.Create(&employee).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := initializers.DB.Delete(&employee).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := initializers.DB.Create(&models.Employee{Name: "John", Email: "john@example.com", BirthDate: "1991-02-28"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	book := models.Book{Title: "Go", Price: 100, Category: "IT"}
+	if err := initializers.DB.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := initializers.DB.Delete(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+	if w := performRequest(r, http.MethodPost, "/api/books/create", gin.H{"title": "Go", "price": 50, "category": "IT"}, bearer(accessToken)); w.Code != http.StatusOK {
+		t.Fatalf("the title of a trashed book could not be reused, got %d: %s", w.Code, w.Body.String())
+	}
+
+	cases := []struct {
+		name   string
+		path   string
+		fields []string
+		model  interface{}
+		id     interface{}
+	}{
+		{"user", fmt.Sprintf("/api/users/%d/restore", jane.ID), []string{"Email"}, &models.User{}, jane.ID},
+		{"employee", fmt.Sprintf("/api/customers/%d/restore", employee.ID), []string{"Name", "Email"}, &models.Employee{}, employee.ID},
+		{"book", fmt.Sprintf("/api/books/%d/restore", book.ID), []string{"Title"}, &models.Book{}, book.ID},
+	}
+
+	for _, tc := range cases {
+		w := performRequest(r, http.MethodPost, tc.path, nil, bearer(accessToken))
+		if w.Code != http.StatusConflict {
+			t.Errorf("%s: expected 409, got %d: %s", tc.name, w.Code, w.Body.String())
+			continue
+		}
+
+		conflicts, _ := decodeBody(t, w)["validations"].(map[string]interface{})
+		for _, field := range tc.fields {
+			if conflicts[field] == nil {
+				t.Errorf("%s: expected a conflict on %s, got %v", tc.name, field, conflicts)
+			}
+		}
+
+		if err := initializers.DB.First(tc.model, tc.id).Error; err == nil {
+			t.Errorf("%s: the record was restored despite the conflict", tc.name)
+		}
+	}
+}
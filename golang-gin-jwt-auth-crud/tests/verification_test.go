@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/db/initializers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/helpers"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/models"
+	"github.com/RakibSiddiquee/golang-gin-jwt-auth-crud/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerificationLinkIsSingleUse(t *testing.T) {
+	r := newTestApp(t)
+	box := useMailbox(t)
+
+	w := performRequest(r, http.MethodPost, "/api/signup", gin.H{
+		"name":     "Jane",
+		"email":    "jane@example.com",
+		"password": testPassword,
+	}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("the signup failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	login := gin.H{"email": "jane@example.com", "password": testPassword}
+	if w := performRequest(r, http.MethodPost, "/api/login", login, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected an unverified login to be rejected, got %d", w.Code)
+	}
+
+	link := "/api/verify-email?token=" + url.QueryEscape(box.mailedToken(t))
+	if w := performRequest(r, http.MethodGet, link, nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("the verification failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := performRequest(r, http.MethodGet, link, nil, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected the used link to be rejected, got %d", w.Code)
+	}
+
+	if w := performRequest(r, http.MethodPost, "/api/login", login, nil); w.Code != http.StatusOK {
+		t.Fatalf("the verified login failed with %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestExpiredVerificationLinkIsRejected(t *testing.T) {
+	r := newTestApp(t)
+
+	hash, err := helpers.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{Name: "Jane", Email: "jane@example.com", Password: hash}
+	if err := initializers.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, err := tokens.GenerateTypedToken(tokens.TypeEmailVerification, user.ID, -time.Minute, jwt.MapClaims{
+		"email": user.Email,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := performRequest(r, http.MethodGet, "/api/verify-email?token="+url.QueryEscape(token), nil, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected the expired link to be rejected, got %d", w.Code)
+	}
+
+	initializers.DB.First(&user, user.ID)
+	if user.EmailVerifiedAt != nil {
+		t.Fatal("the expired link verified the email")
+	}
+}
+
+func TestVerificationLinkWithoutJTIIsRejected(t *testing.T) {
+	r := newTestApp(t)
+
+	user := models.User{Name: "Jane", Email: "jane@example.com"}
+	if err := initializers.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// A validly signed link that cannot be marked as used
+	token, _, err := tokens.GenerateTypedToken(tokens.TypeEmailVerification, user.ID, time.Hour, jwt.MapClaims{
+		"email": user.Email,
+		"jti":   nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := performRequest(r, http.MethodGet, "/api/verify-email?token="+url.QueryEscape(token), nil, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected the link without jti to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	initializers.DB.First(&user, user.ID)
+	if user.EmailVerifiedAt != nil {
+		t.Fatal("the link without jti verified the email")
+	}
+}